@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Batch is one free asset drop, as announced by a dispatch article
+type Batch struct {
+	ArticleURL string    `json:"article_url"`
+	Announced  time.Time `json:"announced"`
+	ValidFrom  time.Time `json:"valid_from"`
+	ValidUntil time.Time `json:"valid_until"`
+	AssetURLs  []string  `json:"asset_urls"`
+}
+
+// listRow is a line in an asset list: a section heading or an asset
+type listRow struct {
+	Heading string
+	Asset   Asset
+}
+
+// mergeBatches records new batches and folds newly found assets and
+// deadlines into ones already known
+func mergeBatches(d *AppData, batches []Batch) {
+	if d.Batches == nil {
+		d.Batches = make(map[string]Batch)
+	}
+	for _, b := range batches {
+		existing, ok := d.Batches[b.ArticleURL]
+		if !ok {
+			d.Batches[b.ArticleURL] = b
+			continue
+		}
+		for _, url := range b.AssetURLs {
+			if !containsString(existing.AssetURLs, url) {
+				existing.AssetURLs = append(existing.AssetURLs, url)
+			}
+		}
+		if !b.ValidUntil.IsZero() {
+			existing.ValidUntil = b.ValidUntil
+		}
+		if existing.Announced.IsZero() {
+			existing.Announced = b.Announced
+			existing.ValidFrom = b.ValidFrom
+		}
+		d.Batches[b.ArticleURL] = existing
+	}
+}
+
+// sortedBatches returns all batches, newest first
+func sortedBatches(d *AppData) []Batch {
+	var batches []Batch
+	for _, b := range d.Batches {
+		batches = append(batches, b)
+	}
+	sort.Slice(batches, func(i, j int) bool {
+		return batches[i].Announced.After(batches[j].Announced)
+	})
+	return batches
+}
+
+// groupByBatch splits free assets into sections per batch (sorted newest
+// first), keeping the given order within each section. An asset offered in
+// several batches is listed under the newest one.
+func groupByBatch(assets []Asset, batches []Batch, now time.Time) []listRow {
+	batchOf := make(map[string]int)
+	for i := len(batches) - 1; i >= 0; i-- {
+		for _, url := range batches[i].AssetURLs {
+			batchOf[url] = i
+		}
+	}
+
+	sections := make([][]Asset, len(batches))
+	var other []Asset
+	for _, a := range assets {
+		if i, ok := batchOf[a.URL]; ok {
+			sections[i] = append(sections[i], a)
+		} else {
+			other = append(other, a)
+		}
+	}
+
+	var rows []listRow
+	for i, b := range batches {
+		if len(sections[i]) == 0 {
+			continue
+		}
+		rows = append(rows, listRow{Heading: batchHeading(b, i, now)})
+		for _, a := range sections[i] {
+			rows = append(rows, listRow{Asset: a})
+		}
+	}
+	if len(other) > 0 {
+		if len(rows) > 0 {
+			rows = append(rows, listRow{Heading: "Other free assets"})
+		}
+		for _, a := range other {
+			rows = append(rows, listRow{Asset: a})
+		}
+	}
+	return rows
+}
+
+func batchHeading(b Batch, index int, now time.Time) string {
+	var name string
+	switch {
+	case index == 0 && (b.ValidUntil.IsZero() || now.Before(b.ValidUntil)):
+		name = "Current batch"
+	case index == 0:
+		name = "Latest batch"
+	case index == 1:
+		name = "Previous batch"
+	case b.Announced.IsZero():
+		name = "Earlier batch"
+	default:
+		name = "Batch of " + b.Announced.Local().Format("Jan 2, 2006")
+	}
+
+	switch {
+	case !b.ValidFrom.IsZero() && !b.ValidUntil.IsZero():
+		return fmt.Sprintf("%s • %s – %s", name, b.ValidFrom.Local().Format("Jan 2"), b.ValidUntil.Local().Format("Jan 2"))
+	case !b.ValidUntil.IsZero():
+		return fmt.Sprintf("%s • until %s", name, b.ValidUntil.Local().Format("Jan 2"))
+	}
+	return name
+}
+
+// groupGames splits free games into those free now, those announced for
+// later and those whose offer has ended, keeping the given order
+func groupGames(games []Asset, now time.Time) []listRow {
+	var current, upcoming, ended []Asset
+	for _, g := range games {
+		switch {
+		case isExpired(g, now):
+			ended = append(ended, g)
+		case now.Before(g.StartsAt):
+			upcoming = append(upcoming, g)
+		default:
+			current = append(current, g)
+		}
+	}
+
+	var rows []listRow
+	for _, section := range []struct {
+		heading string
+		games   []Asset
+	}{
+		{"Free now", current},
+		{"Coming next", upcoming},
+		{"Past free games", ended},
+	} {
+		if len(section.games) == 0 {
+			continue
+		}
+		rows = append(rows, listRow{Heading: section.heading})
+		rows = append(rows, assetRows(section.games)...)
+	}
+	return rows
+}
+
+// assetRows wraps assets as list rows without headings
+func assetRows(assets []Asset) []listRow {
+	rows := make([]listRow, len(assets))
+	for i, a := range assets {
+		rows[i] = listRow{Asset: a}
+	}
+	return rows
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
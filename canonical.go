@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	fabListingPattern  = regexp.MustCompile(`(?i)/listings/([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})`)
+	marketplacePattern = regexp.MustCompile(`(?i)^/marketplace/(?:[a-z]{2}(?:-[a-z]{2})?/)?product/([^/]+)`)
+	epicStorePattern   = regexp.MustCompile(`(?i)^/(?:[a-z]{2}(?:-[a-z]{2})?/)?(p|bundles)/([^/]+)`)
+	dispatchArticle    = regexp.MustCompile(`^/d/([^/]+)/?$`)
+)
+
+// Query parameters that only track where a click came from
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true, "igshid": true,
+	"mc_cid": true, "mc_eid": true, "ref": true, "ref_src": true,
+}
+
+// Hosts whose links only redirect somewhere else
+var shortLinkHosts = map[string]bool{
+	"bit.ly": true, "t.co": true, "tinyurl.com": true, "buff.ly": true, "ow.ly": true, "epic.gm": true,
+}
+
+// canonicalURL reduces a listing or article URL to the key it's stored
+// under: Fab listings become https://www.fab.com/listings/<uuid> whatever
+// their locale, slug, trailing slash or query; unrealsource.com articles
+// become https://unrealsource.com/d/<slug>/ whatever their scheme, trailing
+// slash or query; legacy marketplace products and Epic Games Store pages
+// lose their locale; other URLs lose tracking parameters and fragments.
+func canonicalURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	host := strings.ToLower(u.Host)
+
+	switch strings.TrimPrefix(host, "www.") {
+	case "fab.com":
+		if m := fabListingPattern.FindStringSubmatch(u.Path); m != nil {
+			return "https://www.fab.com/listings/" + strings.ToLower(m[1])
+		}
+		host = "www.fab.com"
+	case "store.epicgames.com":
+		if m := epicStorePattern.FindStringSubmatch(u.Path); m != nil {
+			return "https://store.epicgames.com/" + strings.ToLower(m[1]) + "/" + strings.ToLower(m[2])
+		}
+	case "unrealsource.com":
+		if m := dispatchArticle.FindStringSubmatch(u.Path); m != nil {
+			return "https://unrealsource.com/d/" + m[1] + "/"
+		}
+	case "unrealengine.com":
+		if m := marketplacePattern.FindStringSubmatch(u.Path); m != nil {
+			return "https://www.unrealengine.com/marketplace/product/" + strings.ToLower(m[1])
+		}
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = host
+	u.Fragment = ""
+	if u.RawQuery != "" {
+		q := u.Query()
+		for key := range q {
+			if trackingParams[strings.ToLower(key)] || strings.HasPrefix(strings.ToLower(key), "utm_") {
+				q.Del(key)
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+// needsRedirect reports whether a canonical URL only points at the real
+// page: short links, and legacy marketplace products that moved to Fab
+func needsRedirect(canonical string) bool {
+	u, err := url.Parse(canonical)
+	if err != nil {
+		return false
+	}
+	host := strings.TrimPrefix(u.Host, "www.")
+	return shortLinkHosts[host] || (host == "unrealengine.com" && strings.HasPrefix(u.Path, "/marketplace/product/"))
+}
+
+var (
+	redirectsMu sync.Mutex
+	redirects   = make(map[string]string)
+)
+
+// resolveRedirect follows a short or legacy link and returns the canonical
+// URL it ends up at. Results are remembered for the life of the process;
+// failures aren't, so the link is followed again next time.
+func resolveRedirect(ctx context.Context, client *http.Client, link string) (string, error) {
+	redirectsMu.Lock()
+	target, ok := redirects[link]
+	redirectsMu.Unlock()
+	if ok {
+		return target, nil
+	}
+
+	resp, err := fetch(withoutHTTPCache(ctx), client, link)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	target = canonicalURL(resp.Request.URL.String())
+
+	redirectsMu.Lock()
+	redirects[link] = target
+	redirectsMu.Unlock()
+	return target, nil
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Hooks for the GUI, which main sets up. Checks call them from their own
+// goroutine; without a window they do nothing.
+var (
+	// The list view was refreshed from the app state
+	onListsChanged = func() {}
+	onNewAssets    = func(assets []Asset, category string) {}
+	onSourceBroken = func(name, reason string) {}
+	// A save failed after the previous one succeeded
+	onStoreError = func(err error) {}
+)
+
+// listsChanged refreshes the list view after the app state changed
+func listsChanged() {
+	view.refresh()
+	onListsChanged()
+}
+
+func backgroundChecker() {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		checkForAssets()
+	}
+}
+
+// checkForAssets runs a check. Requests made while one is running are
+// coalesced into a single check after it.
+func checkForAssets() {
+	if !checks.run("check", runCheck) {
+		log.Println("Check already running; another will follow it")
+	}
+}
+
+func runCheck() {
+	log.Println("Checking for assets...")
+	newFreeAssets := []Asset{}
+	newGameAssets := []Asset{}
+	newPermanentAssets := []Asset{}
+	newLatestAssets := []Asset{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	// Listing and article pages, the sources' included, share one deadline,
+	// so a slow site can't hold up the whole check
+	ctx = withDetailDeadline(ctx)
+	// With nothing stored, "unchanged" pages would leave the lists empty
+	empty := false
+	state.read(func(d *AppData) { empty = len(d.SeenAssets) == 0 })
+	if empty {
+		ctx = withoutHTTPCache(ctx)
+	}
+
+	// Offers that ran out since the last check come first in the history
+	state.update(func(d *AppData) { recordExpiries(d, time.Now()) })
+
+	// Scrape every enabled source for FREE and Latest assets. Validators
+	// of the pages are kept once the results are saved, and only for
+	// sources that got all their pages, so failed ones are fetched again.
+	var validators []*cacheUpdates
+	for _, src := range enabledSources() {
+		updates := &cacheUpdates{}
+		result := fetchSource(withCacheUpdates(ctx, updates), src)
+		if result == nil {
+			continue
+		}
+		if len(result.Errors) == 0 {
+			validators = append(validators, updates)
+		}
+		name := src.Name()
+		state.update(func(d *AppData) {
+			now := time.Now()
+			newFreeAssets = append(newFreeAssets, mergeAssets(d, name, result.Free, now)...)
+			newGameAssets = append(newGameAssets, mergeAssets(d, name, result.Games, now)...)
+			newPermanentAssets = append(newPermanentAssets, mergeAssets(d, name, result.Permanent, now)...)
+			newLatestAssets = append(newLatestAssets, mergeAssets(d, name, result.Latest, now)...)
+			recordListing(d, name, result, now)
+			mergeBatches(d, result.Batches)
+		})
+	}
+
+	detailCtx, cancelDetail := detailContext(ctx)
+	defer cancelDetail()
+
+	// Fill in seller, price etc. from the fab.com listing pages
+	enrichStats := &fetchStats{}
+	enrichStatus := SourceStatus{LastRun: time.Now()}
+	for _, err := range enrichAssets(withFetchStats(detailCtx, enrichStats), httpClient) {
+		log.Printf("Enrichment error: %v", err)
+		enrichStatus.addError(err)
+	}
+	enrichStatus.Requests, enrichStatus.Retries = enrichStats.Requests, enrichStats.Retries
+	state.update(func(d *AppData) {
+		setSourceStatus(d, enrichStageName, enrichStatus)
+		refreshFromState(d, newFreeAssets)
+	})
+
+	// Fetch new news articles once for their real title, author and date
+	articleStats := &fetchStats{}
+	articleStatus := SourceStatus{LastRun: time.Now()}
+	for _, err := range enrichArticles(withFetchStats(detailCtx, articleStats), httpClient) {
+		log.Printf("Article error: %v", err)
+		articleStatus.addError(err)
+	}
+	articleStatus.Requests, articleStatus.Retries = articleStats.Requests, articleStats.Retries
+	state.update(func(d *AppData) {
+		setSourceStatus(d, articleStageName, articleStatus)
+		refreshFromState(d, newLatestAssets)
+		d.LastCheck = time.Now()
+	})
+	if saveData() == nil {
+		for _, updates := range validators {
+			pageCache.commit(updates)
+		}
+	}
+	listsChanged()
+
+	if len(newFreeAssets) > 0 {
+		onNewAssets(newFreeAssets, CategoryFree)
+	}
+	if len(newGameAssets) > 0 {
+		onNewAssets(newGameAssets, CategoryGames)
+	}
+	if len(newPermanentAssets) > 0 {
+		onNewAssets(newPermanentAssets, CategoryPermanent)
+	}
+	if len(newLatestAssets) > 0 {
+		onNewAssets(newLatestAssets, CategoryLatest)
+	}
+
+	log.Printf("Check complete. Found %d new free, %d new games, %d new always free, %d new latest.",
+		len(newFreeAssets), len(newGameAssets), len(newPermanentAssets), len(newLatestAssets))
+}
+
+// fetchSource runs one source and records the outcome, including request
+// retries and failures, in its status. It returns nil if the fetch failed.
+func fetchSource(ctx context.Context, src Source) *FetchResult {
+	stats := &fetchStats{}
+	status := SourceStatus{LastRun: time.Now()}
+	result, err := src.Fetch(withFetchStats(ctx, stats))
+	status.Requests, status.Retries = stats.Requests, stats.Retries
+	if err != nil {
+		log.Printf("%s error: %v", src.Name(), err)
+		status.addError(err)
+		state.update(func(d *AppData) { setSourceStatus(d, src.Name(), status) })
+		return nil
+	}
+	for _, e := range result.Errors {
+		status.addError(e)
+	}
+	status.Found = result.found()
+	newlyBroken := false
+	state.update(func(d *AppData) {
+		prev := d.Sources[src.Name()]
+		if result.NotModified {
+			status.NotModified = true
+			status.Found = prev.Found
+			status.Broken = prev.Broken
+		} else {
+			status.Broken = recordYield(d, src.Name(), result)
+		}
+		newlyBroken = status.Broken != "" && prev.Broken == ""
+		setSourceStatus(d, src.Name(), status)
+	})
+	if newlyBroken {
+		log.Printf("%s looks broken: %s", src.Name(), status.Broken)
+		onSourceBroken(src.Name(), status.Broken)
+	}
+	if stats.Retries > 0 {
+		log.Printf("%s: %d requests, %d retries, %d failed", src.Name(), stats.Requests, stats.Retries, stats.Failures)
+	}
+	return result
+}
+
+func setSourceStatus(d *AppData, name string, status SourceStatus) {
+	if d.Sources == nil {
+		d.Sources = make(map[string]SourceStatus)
+	}
+	// The last listing outlives runs that didn't get one
+	if status.Listed.IsZero() {
+		status.Listed = d.Sources[name].Listed
+	}
+	d.Sources[name] = status
+}
+
+// refreshFromState replaces assets with their stored versions, which
+// enrichment may have filled in since
+func refreshFromState(d *AppData, assets []Asset) {
+	for i, a := range assets {
+		if stored, ok := d.SeenAssets[a.URL]; ok {
+			assets[i] = stored
+		}
+	}
+}
+
+// backfillAssets imports past free batches from every source that keeps an
+// archive. It waits for a running check, and the other way round.
+func backfillAssets() {
+	if !checks.run("backfill", runBackfill) {
+		log.Println("Check or backfill running; backfill will follow it")
+	}
+}
+
+// runBackfill does the import. Imported assets are history, so no
+// notifications are shown.
+func runBackfill() {
+	log.Println("Backfilling past batches...")
+	ctx, cancel := context.WithTimeout(context.Background(), backfillTimeout)
+	defer cancel()
+
+	imported := 0
+	for _, src := range enabledSources() {
+		b, ok := src.(Backfiller)
+		if !ok {
+			continue
+		}
+		result, err := b.Backfill(ctx, backfillPages)
+		if err != nil {
+			log.Printf("%s backfill error: %v", src.Name(), err)
+			continue
+		}
+		for _, e := range result.Errors {
+			log.Printf("%s backfill error: %v", src.Name(), e)
+		}
+		state.update(func(d *AppData) {
+			imported += len(mergeArchived(d, src.Name(), result.Free, time.Now()))
+			mergeBatches(d, result.Batches)
+		})
+	}
+
+	for _, err := range enrichAssets(ctx, httpClient) {
+		log.Printf("Enrichment error: %v", err)
+	}
+	saveData()
+	listsChanged()
+	log.Printf("Backfill complete. Imported %d past free assets.", imported)
+}
+
+// mergeAssets adds unseen assets to d.SeenAssets and returns the new ones.
+// Assets already known have the sighting recorded in their history.
+func mergeAssets(d *AppData, source string, assets []Asset, now time.Time) []Asset {
+	var added []Asset
+	for _, a := range assets {
+		a.URL = canonicalURL(a.URL)
+		existing, seen := d.SeenAssets[a.URL]
+		if !seen {
+			a = newAsset(a, source, now)
+			d.SeenAssets[a.URL] = a
+			added = append(added, a)
+			continue
+		}
+		// A listing seen as free for good that turns up in a free batch
+		// was a promotion after all
+		if existing.Category == CategoryPermanent && a.Category == CategoryFree {
+			a.FirstSeen, a.History = existing.FirstSeen, existing.History
+			a.Source, a.LastSeen = source, now
+			a.addEvent(AssetEvent{Time: now, Type: eventChanged, Field: "category", From: existing.Category, To: a.Category})
+			d.SeenAssets[a.URL] = a
+			added = append(added, a)
+			continue
+		}
+		existing = observeAsset(existing, a, source, now)
+		// Items saved before dates were parsed pick up an estimate
+		if existing.Published.IsZero() && !a.Published.IsZero() {
+			existing.Published, existing.PublishedPrecision = a.Published, a.PublishedPrecision
+		}
+		d.SeenAssets[a.URL] = existing
+	}
+	return added
+}
+
+// mergeArchived adds assets from a source's archive that weren't seen yet.
+// Being in an archive says nothing about whether they're still listed, so
+// known assets are left as they are.
+func mergeArchived(d *AppData, source string, assets []Asset, now time.Time) []Asset {
+	var added []Asset
+	for _, a := range assets {
+		a.URL = canonicalURL(a.URL)
+		if _, seen := d.SeenAssets[a.URL]; !seen {
+			a = newAsset(a, source, now)
+			d.SeenAssets[a.URL] = a
+			added = append(added, a)
+		}
+	}
+	return added
+}
+
+func clearHistory() {
+	state.update(func(d *AppData) {
+		d.SeenAssets = make(map[string]Asset)
+		d.Batches = make(map[string]Batch)
+	})
+	pageCache.clear()
+	saveData()
+	listsChanged()
+	log.Println("History cleared")
+}
+
+func loadData() {
+	if err := state.load(); err != nil {
+		log.Printf("Data load error: %v", err)
+	}
+}
+
+func saveData() error {
+	failedBefore := state.storeError() != nil
+	err := state.save()
+	if err != nil {
+		log.Printf("Data save error: %v", err)
+		if !failedBefore {
+			onStoreError(err)
+		}
+	}
+	return err
+}
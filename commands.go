@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// command is a one-off operation run from the command line instead of
+// starting the tray app, e.g. "unreal-free-assets validate-rules page.html"
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"verify": {
+		usage: "verify [seen_assets.json]  check a data file for damaged or inconsistent entries",
+		run:   verifyCommand,
+	},
+	"import-json": {
+		usage: "import-json [seen_assets.json]  copy seen assets and history from a JSON data file into the SQLite database",
+		run:   importJSONCommand,
+	},
+	"repair": {
+		usage: "repair [-dry-run] [-o out.json] [seen_assets.json]  salvage every readable asset of a damaged data file into a fresh one",
+		run:   repairCommand,
+	},
+	"validate-rules": {
+		usage: "validate-rules [rules.json] page.html...  run a rules file's tests and show what each rule matches in saved pages",
+		run:   validateRulesCommand,
+	},
+}
+
+// runCommand runs the command named by args[0] and returns the exit code
+func runCommand(args []string) int {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\ncommands:\n", args[0])
+		var names []string
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+		}
+		return 2
+	}
+	if err := cmd.run(args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func validateRulesCommand(args []string) error {
+	rules := scraperRules
+	if len(args) > 0 && strings.HasSuffix(strings.ToLower(args[0]), ".json") {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		if rules, err = parseRules(data, args[0]); err != nil {
+			return err
+		}
+		args = args[1:]
+	}
+	fmt.Printf("Rules: %s (version %d)\n", rules.origin, rules.Version)
+
+	failures := rules.runTests()
+	for _, f := range failures {
+		fmt.Printf("FAIL %s\n", f)
+	}
+	tests := 0
+	for _, rule := range rules.Rules {
+		tests += len(rule.Tests)
+	}
+	fmt.Printf("%d/%d rule tests passed\n", tests-len(failures), tests)
+
+	for _, path := range args {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		doc, err := goquery.NewDocumentFromReader(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		fmt.Printf("\n%s\n", path)
+		for _, name := range rules.ruleNames() {
+			rule := rules.Rules[name]
+			if len(rule.Selectors) > 0 {
+				found, selector := rule.find(doc.Selection)
+				if found.Length() == 0 {
+					fmt.Printf("  MISS %-24s no selector matched\n", name)
+					continue
+				}
+				fmt.Printf("  OK   %-24s %d matches via %s\n", name, found.Length(), selector)
+				continue
+			}
+			if m := rule.match(doc.Text()); m != nil {
+				fmt.Printf("  OK   %-24s %q\n", name, strings.Join(strings.Fields(m[""]), " "))
+			} else {
+				fmt.Printf("  MISS %-24s no pattern matched\n", name)
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%d rule tests failed", len(failures))
+	}
+	return nil
+}
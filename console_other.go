@@ -0,0 +1,7 @@
+//go:build !windows
+
+package main
+
+// attachParentConsole is only needed on Windows, where the app is linked
+// without a console
+func attachParentConsole() {}
@@ -0,0 +1,36 @@
+//go:build windows
+
+package main
+
+import (
+	"log"
+	"os"
+	"syscall"
+)
+
+// attachParentProcess is ATTACH_PARENT_PROCESS: the console of the process
+// that started this one
+const attachParentProcess = ^uint32(0)
+
+// attachParentConsole sends command output to the console the app was
+// started from. The app is linked as a GUI program (-H windowsgui) and has
+// no console of its own, so what verify or repair print would be lost.
+// Output redirected to a file or pipe is left alone.
+func attachParentConsole() {
+	attach := syscall.NewLazyDLL("kernel32.dll").NewProc("AttachConsole")
+	if ok, _, _ := attach.Call(uintptr(attachParentProcess)); ok == 0 {
+		// Started from Explorer or a shortcut: there's no console to use
+		return
+	}
+	if _, err := os.Stdout.Stat(); err != nil {
+		if out, err := os.OpenFile("CONOUT$", os.O_WRONLY, 0); err == nil {
+			os.Stdout = out
+		}
+	}
+	if _, err := os.Stderr.Stat(); err != nil {
+		if out, err := os.OpenFile("CONOUT$", os.O_WRONLY, 0); err == nil {
+			os.Stderr = out
+		}
+	}
+	log.SetOutput(os.Stderr)
+}
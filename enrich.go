@@ -0,0 +1,439 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Max listing or article pages fetched per check, so a large backlog doesn't stall a run
+const maxEnrichPerRun = 40
+
+// A page that failed to fetch is tried again after enrichBackoff, doubled
+// with every further failure, and given up on after maxEnrichFailures
+const (
+	enrichBackoff     = time.Hour
+	maxEnrichFailures = 6
+)
+
+var (
+	pricePattern         = regexp.MustCompile(`[$€£]\s*\d+(?:[.,]\d{2})?|\d+(?:[.,]\d{2})?\s*(?:USD|EUR|GBP)`)
+	engineSectionPattern = regexp.MustCompile(`(?i)(?:supported\s+)?unreal\s+engine\s+versions?[:\s]*((?:\d\.\d+[\s,\-–]*(?:and|to)?\s*)+)`)
+	engineVersionPattern = regexp.MustCompile(`\d\.\d+`)
+)
+
+// listingDetails is the metadata scraped from a fab.com listing page
+type listingDetails struct {
+	Title          string   `json:"title"`
+	Seller         string   `json:"seller"`
+	Category       string   `json:"category"`
+	OriginalPrice  string   `json:"original_price"`
+	Description    string   `json:"description"`
+	Thumbnail      string   `json:"thumbnail"`
+	EngineVersions []string `json:"engine_versions"`
+}
+
+// apply copies the scraped details onto an asset, keeping existing values
+// where the listing page had nothing better
+func (d *listingDetails) apply(a *Asset) {
+	if a.Title == "" && d.Title != "" {
+		a.Title = d.Title
+	}
+	if d.Seller != "" {
+		a.Seller = d.Seller
+	}
+	if d.Category != "" {
+		a.ListingCategory = d.Category
+	}
+	if d.OriginalPrice != "" {
+		a.OriginalPrice = d.OriginalPrice
+	}
+	if d.Description != "" {
+		a.Description = d.Description
+	}
+	if d.Thumbnail != "" {
+		a.Thumbnail = d.Thumbnail
+	}
+	if len(d.EngineVersions) > 0 {
+		a.EngineVersions = d.EngineVersions
+	}
+}
+
+// isListingURL reports whether url points at a fab.com listing page
+func isListingURL(url string) bool {
+	return strings.Contains(url, "fab.com/listings")
+}
+
+// enrichAssets fetches the listing page of every unenriched free asset and
+// stores the details on it. Errors are returned rather than logged so the
+// caller can record them as a status.
+func enrichAssets(ctx context.Context, client *http.Client) []error {
+	ctx = withoutHTTPCache(ctx)
+	var keys []string
+	state.read(func(d *AppData) {
+		keys = unenriched(d, func(a Asset) bool {
+			return a.Category == CategoryFree && isListingURL(a.URL)
+		})
+	})
+
+	// Fetch on the worker pool, then apply the details in one update
+	details := make([]*listingDetails, len(keys))
+	fetchErrs := make([]error, len(keys))
+	forEachLimit(ctx, config.Concurrency, len(keys), func(i int) {
+		details[i], fetchErrs[i] = fetchListingDetails(ctx, client, keys[i])
+	})
+
+	var errs []error
+	state.update(func(d *AppData) {
+		for i, key := range keys {
+			a, ok := d.SeenAssets[key]
+			if details[i] == nil {
+				errs = append(errs, skippedError(ctx, fetchErrs[i], key))
+				if ok && fetchErrs[i] != nil {
+					enrichFailed(&a, fetchErrs[i], time.Now())
+					d.SeenAssets[key] = a
+				}
+				continue
+			}
+			if !ok {
+				// History was cleared while fetching
+				continue
+			}
+			details[i].apply(&a)
+			a.EnrichedAt = time.Now()
+			d.SeenAssets[key] = a
+		}
+	})
+	if len(keys) > 0 {
+		log.Printf("Enriched %d listings, %d errors", len(keys)-len(errs), len(errs))
+	}
+	return errs
+}
+
+// unenriched returns up to maxEnrichPerRun keys of assets matching want
+// whose details haven't been fetched yet and are due for a try, oldest first
+func unenriched(d *AppData, want func(Asset) bool) []string {
+	now := time.Now()
+	var keys []string
+	for key, a := range d.SeenAssets {
+		if a.EnrichedAt.IsZero() && !now.Before(a.EnrichRetryAt) && want(a) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return d.SeenAssets[keys[i]].FirstSeen.Before(d.SeenAssets[keys[j]].FirstSeen)
+	})
+	if len(keys) > maxEnrichPerRun {
+		keys = keys[:maxEnrichPerRun]
+	}
+	return keys
+}
+
+// enrichFailed records a failed fetch of an asset's listing or article
+// page. A page that's gone or forbidden (4xx, or disallowed by robots.txt)
+// won't come back, so it's marked done with the details the asset has;
+// other failures are retried later, less often each time.
+func enrichFailed(a *Asset, err error, now time.Time) {
+	var fe *fetchError
+	permanent := errors.Is(err, errRobotsDisallowed) ||
+		errors.As(err, &fe) && fe.Status >= 400 && fe.Status < 500 && fe.Status != http.StatusTooManyRequests
+	a.EnrichFailures++
+	if permanent || a.EnrichFailures >= maxEnrichFailures {
+		a.EnrichedAt = now
+		return
+	}
+	a.EnrichRetryAt = now.Add(enrichBackoff << (a.EnrichFailures - 1))
+}
+
+// articleDetails is the metadata scraped from a news article
+type articleDetails struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Author      string    `json:"author"`
+	Published   time.Time `json:"published"`
+	Image       string    `json:"image"`
+}
+
+// apply copies the scraped details onto a news item. The article's own
+// title only replaces one guessed from the URL (items saved before titles
+// were flagged are recognised by comparing with the slug).
+func (d *articleDetails) apply(a *Asset) {
+	guessed := a.TitleGuessed || a.Title == "" || a.Title == titleFromSlug(a.URL)
+	if d.Title != "" && guessed {
+		a.Title = d.Title
+		a.TitleGuessed = false
+	}
+	if d.Description != "" {
+		a.Description = d.Description
+	}
+	if d.Author != "" {
+		a.Author = d.Author
+	}
+	if !d.Published.IsZero() {
+		a.Published, a.PublishedPrecision = d.Published, precisionExact
+	}
+	if d.Image != "" {
+		a.Thumbnail = d.Image
+	}
+}
+
+// enrichArticles fetches every news article not fetched before and stores
+// its title, description, author, date and image on it. Articles that
+// fail to load are retried with backoff, like listings.
+func enrichArticles(ctx context.Context, client *http.Client) []error {
+	ctx = withoutHTTPCache(ctx)
+	var keys []string
+	state.read(func(d *AppData) {
+		keys = unenriched(d, func(a Asset) bool {
+			return a.Category == CategoryLatest
+		})
+	})
+
+	details := make([]*articleDetails, len(keys))
+	fetchErrs := make([]error, len(keys))
+	forEachLimit(ctx, config.Concurrency, len(keys), func(i int) {
+		doc, err := fetchDocument(ctx, client, keys[i])
+		if err != nil {
+			fetchErrs[i] = err
+			return
+		}
+		details[i] = parseArticlePage(doc)
+	})
+
+	var errs []error
+	state.update(func(d *AppData) {
+		for i, key := range keys {
+			a, ok := d.SeenAssets[key]
+			if details[i] == nil {
+				errs = append(errs, skippedError(ctx, fetchErrs[i], key))
+				if ok && fetchErrs[i] != nil {
+					enrichFailed(&a, fetchErrs[i], time.Now())
+					d.SeenAssets[key] = a
+				}
+				continue
+			}
+			if !ok {
+				continue
+			}
+			details[i].apply(&a)
+			a.EnrichedAt = time.Now()
+			d.SeenAssets[key] = a
+		}
+	})
+	if len(keys) > 0 {
+		log.Printf("Fetched %d articles, %d errors", len(keys)-len(errs), len(errs))
+	}
+	return errs
+}
+
+// parseArticlePage extracts article metadata from OpenGraph and standard
+// meta tags, then JSON-LD, then the page itself
+func parseArticlePage(doc *goquery.Document) *articleDetails {
+	d := &articleDetails{
+		Title:       metaContent(doc, "og:title"),
+		Description: metaContent(doc, "og:description"),
+		Author:      metaContent(doc, "author"),
+		Published:   articleDate(doc),
+		Image:       metaContent(doc, "og:image"),
+	}
+	if d.Title == "" {
+		d.Title = metaContent(doc, "twitter:title")
+	}
+	if d.Description == "" {
+		d.Description = metaContent(doc, "description")
+	}
+	if d.Author == "" {
+		d.Author = metaContent(doc, "article:author")
+	}
+
+	doc.Find("script[type='application/ld+json']").Each(func(i int, s *goquery.Selection) {
+		var raw interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &raw); err != nil {
+			return
+		}
+		for _, node := range jsonLDNodes(raw) {
+			if d.Author == "" {
+				d.Author = jsonLDName(node["author"])
+			}
+			if d.Published.IsZero() {
+				if t, err := time.Parse(time.RFC3339, jsonLDString(node["datePublished"])); err == nil {
+					d.Published = t
+				}
+			}
+		}
+	})
+
+	if d.Author == "" {
+		d.Author = strings.TrimSpace(doc.Find("[rel='author'], .author").First().Text())
+	}
+	if d.Title == "" {
+		d.Title = strings.TrimSpace(doc.Find("h1").First().Text())
+	}
+	if d.Title == "" {
+		d.Title = strings.TrimSpace(doc.Find("title").First().Text())
+	}
+	d.Title = trimSiteName(d.Title, metaContent(doc, "og:site_name"))
+	return d
+}
+
+// trimSiteName removes a " - Site" or " | Site" suffix from a page title
+func trimSiteName(title, site string) string {
+	if site == "" {
+		return title
+	}
+	for _, sep := range []string{" - ", " | ", " – ", " — "} {
+		if strings.HasSuffix(title, sep+site) {
+			return strings.TrimSpace(strings.TrimSuffix(title, sep+site))
+		}
+	}
+	return title
+}
+
+func fetchListingDetails(ctx context.Context, client *http.Client, url string) (*listingDetails, error) {
+	doc, err := fetchDocument(ctx, client, url)
+	if err != nil {
+		return nil, err
+	}
+	return parseListingPage(doc), nil
+}
+
+// parseListingPage extracts listing metadata, preferring JSON-LD, then
+// OpenGraph tags, then the visible page content
+func parseListingPage(doc *goquery.Document) *listingDetails {
+	d := &listingDetails{}
+
+	doc.Find("script[type='application/ld+json']").Each(func(i int, s *goquery.Selection) {
+		var raw interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &raw); err != nil {
+			return
+		}
+		for _, node := range jsonLDNodes(raw) {
+			applyJSONLD(d, node)
+		}
+	})
+
+	if d.Title == "" {
+		d.Title = metaContent(doc, "og:title")
+	}
+	if d.Description == "" {
+		d.Description = metaContent(doc, "og:description")
+	}
+	if d.Thumbnail == "" {
+		d.Thumbnail = metaContent(doc, "og:image")
+	}
+
+	// The struck-through price is the one the asset normally sells for
+	prices, _ := scraperRules.rule("listing_original_price").find(doc.Selection)
+	prices.EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if price := pricePattern.FindString(s.Text()); price != "" {
+			d.OriginalPrice = strings.TrimSpace(price)
+			return false
+		}
+		return true
+	})
+
+	if m := engineSectionPattern.FindStringSubmatch(doc.Text()); len(m) > 1 {
+		d.EngineVersions = engineVersionPattern.FindAllString(m[1], -1)
+	}
+
+	d.Description = strings.TrimSpace(d.Description)
+	return d
+}
+
+// jsonLDNodes flattens a JSON-LD document (object, array or @graph) into its nodes
+func jsonLDNodes(raw interface{}) []map[string]interface{} {
+	var nodes []map[string]interface{}
+	switch v := raw.(type) {
+	case []interface{}:
+		for _, item := range v {
+			nodes = append(nodes, jsonLDNodes(item)...)
+		}
+	case map[string]interface{}:
+		nodes = append(nodes, v)
+		if graph, ok := v["@graph"]; ok {
+			nodes = append(nodes, jsonLDNodes(graph)...)
+		}
+	}
+	return nodes
+}
+
+func applyJSONLD(d *listingDetails, node map[string]interface{}) {
+	switch jsonLDString(node["@type"]) {
+	case "Product", "SoftwareApplication", "CreativeWork":
+	default:
+		return
+	}
+	if d.Title == "" {
+		d.Title = jsonLDString(node["name"])
+	}
+	if d.Description == "" {
+		d.Description = jsonLDString(node["description"])
+	}
+	if d.Thumbnail == "" {
+		d.Thumbnail = jsonLDString(node["image"])
+	}
+	if d.Category == "" {
+		d.Category = jsonLDString(node["category"])
+	}
+	if d.Seller == "" {
+		for _, key := range []string{"brand", "author", "seller", "creator"} {
+			if name := jsonLDName(node[key]); name != "" {
+				d.Seller = name
+				break
+			}
+		}
+	}
+	if offers, ok := node["offers"].(map[string]interface{}); ok && d.Seller == "" {
+		d.Seller = jsonLDName(offers["seller"])
+	}
+}
+
+// jsonLDString returns a string value, or the first string of an array
+func jsonLDString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return strings.TrimSpace(t)
+	case []interface{}:
+		if len(t) > 0 {
+			return jsonLDString(t[0])
+		}
+	case map[string]interface{}:
+		// ImageObject and similar
+		if url, ok := t["url"].(string); ok {
+			return url
+		}
+	case float64:
+		return fmt.Sprint(t)
+	}
+	return ""
+}
+
+// jsonLDName returns the name of an Organization/Person value
+func jsonLDName(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return strings.TrimSpace(t)
+	case map[string]interface{}:
+		return jsonLDString(t["name"])
+	case []interface{}:
+		if len(t) > 0 {
+			return jsonLDName(t[0])
+		}
+	}
+	return ""
+}
+
+func metaContent(doc *goquery.Document, property string) string {
+	sel := doc.Find(fmt.Sprintf("meta[property='%s'], meta[name='%s']", property, property)).First()
+	content, _ := sel.Attr("content")
+	return strings.TrimSpace(content)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestEnrichFailedBacksOff(t *testing.T) {
+	now := time.Date(2025, 1, 7, 15, 0, 0, 0, time.UTC)
+	listing := "https://www.fab.com/listings/00000000-0000-4000-8000-000000000001"
+
+	gone := Asset{URL: listing, Category: CategoryFree}
+	enrichFailed(&gone, &fetchError{URL: listing, Status: http.StatusNotFound, Attempts: 1}, now)
+	if !gone.EnrichedAt.Equal(now) {
+		t.Errorf("404 listing not marked done: enriched at %v", gone.EnrichedAt)
+	}
+
+	disallowed := Asset{URL: listing, Category: CategoryFree}
+	enrichFailed(&disallowed, fmt.Errorf("%s: %w", listing, errRobotsDisallowed), now)
+	if !disallowed.EnrichedAt.Equal(now) {
+		t.Errorf("listing disallowed by robots.txt not marked done: enriched at %v", disallowed.EnrichedAt)
+	}
+
+	flaky := Asset{URL: listing, Category: CategoryFree}
+	enrichFailed(&flaky, &fetchError{URL: listing, Status: http.StatusBadGateway, Attempts: fetchMaxAttempts}, now)
+	if !flaky.EnrichedAt.IsZero() || !flaky.EnrichRetryAt.Equal(now.Add(enrichBackoff)) {
+		t.Fatalf("after 1 failure: enriched at %v, retry at %v", flaky.EnrichedAt, flaky.EnrichRetryAt)
+	}
+	enrichFailed(&flaky, errors.New("connection reset"), now)
+	if !flaky.EnrichRetryAt.Equal(now.Add(2 * enrichBackoff)) {
+		t.Errorf("after 2 failures: retry at %v, want %v", flaky.EnrichRetryAt, now.Add(2*enrichBackoff))
+	}
+	for flaky.EnrichFailures < maxEnrichFailures {
+		enrichFailed(&flaky, errors.New("connection reset"), now)
+	}
+	if flaky.EnrichedAt.IsZero() {
+		t.Errorf("still retrying after %d failures", flaky.EnrichFailures)
+	}
+
+	waiting := Asset{URL: listing, Category: CategoryFree, EnrichRetryAt: time.Now().Add(time.Hour)}
+	d := &AppData{SeenAssets: map[string]Asset{listing: waiting}}
+	if keys := unenriched(d, func(Asset) bool { return true }); len(keys) != 0 {
+		t.Errorf("unenriched picked %v before its retry time", keys)
+	}
+}
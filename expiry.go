@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // Windows has no zoneinfo database of its own
+)
+
+// US timezone abbreviations as Epic writes them. Generic ones (ET, PT)
+// follow daylight saving; explicit ones are fixed offsets.
+var expiryZones = map[string]struct {
+	location string
+	offset   int
+}{
+	"ET":  {"America/New_York", -5},
+	"EST": {"", -5},
+	"EDT": {"", -4},
+	"CT":  {"America/Chicago", -6},
+	"CST": {"", -6},
+	"CDT": {"", -5},
+	"MT":  {"America/Denver", -7},
+	"MST": {"", -7},
+	"MDT": {"", -6},
+	"PT":  {"America/Los_Angeles", -8},
+	"PST": {"", -8},
+	"PDT": {"", -7},
+	"UTC": {"", 0},
+	"GMT": {"", 0},
+}
+
+// Epic announces deadlines in Eastern time unless stated otherwise
+const defaultExpiryZone = "ET"
+
+// parseExpiry finds a deadline in page text using the "expiry" scraper
+// rule. ref anchors dates written without a year (the article date, or
+// now). It returns the deadline, the phrase it was parsed from for
+// display, and whether one was found.
+func parseExpiry(text string, ref time.Time) (time.Time, string, bool) {
+	m := scraperRules.rule("expiry").match(text)
+	if m == nil {
+		return time.Time{}, "", false
+	}
+
+	month, ok := parseMonth(m["month"])
+	if !ok {
+		return time.Time{}, "", false
+	}
+	day, _ := strconv.Atoi(m["day"])
+
+	zone := strings.ToUpper(m["zone"])
+	if zone == "" {
+		zone = defaultExpiryZone
+	}
+	loc := expiryLocation(zone)
+
+	// Without a time of day the offer runs to the end of that day
+	hour, minute, second := 23, 59, 59
+	if h := m["hour"]; h != "" {
+		hour, _ = strconv.Atoi(h)
+		minute, _ = strconv.Atoi(m["minute"])
+		second = 0
+		if ampm := m["ampm"]; ampm != "" {
+			hour %= 12
+			if strings.EqualFold(ampm, "p") {
+				hour += 12
+			}
+		}
+	}
+
+	if ref.IsZero() {
+		ref = time.Now()
+	}
+	year := ref.In(loc).Year()
+	explicitYear := m["year"] != ""
+	if explicitYear {
+		year, _ = strconv.Atoi(m["year"])
+	}
+
+	at := time.Date(year, month, day, hour, minute, second, 0, loc)
+	// A yearless date well before the reference means the deadline is next year
+	if !explicitYear && at.Before(ref.AddDate(0, -1, 0)) {
+		at = at.AddDate(1, 0, 0)
+	}
+	if at.Day() != day || hour > 23 || minute > 59 {
+		// e.g. February 30
+		return time.Time{}, "", false
+	}
+
+	raw := strings.Join(strings.Fields(m[""]), " ")
+	return at, "Free " + raw, true
+}
+
+// parseMonth accepts month names, abbreviations and numbers
+func parseMonth(s string) (time.Month, bool) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return time.Month(n), n >= 1 && n <= 12
+	}
+	s = strings.ToLower(strings.TrimSuffix(s, "."))
+	if len(s) < 3 {
+		return 0, false
+	}
+	for m := time.January; m <= time.December; m++ {
+		if strings.HasPrefix(strings.ToLower(m.String()), s[:3]) {
+			return m, true
+		}
+	}
+	return 0, false
+}
+
+func expiryLocation(zone string) *time.Location {
+	z, ok := expiryZones[zone]
+	if !ok {
+		z = expiryZones[defaultExpiryZone]
+	}
+	if z.location != "" {
+		if loc, err := time.LoadLocation(z.location); err == nil {
+			return loc
+		}
+	}
+	return time.FixedZone(zone, z.offset*3600)
+}
+
+// isExpired reports whether a free asset's deadline has passed
+func isExpired(a Asset, now time.Time) bool {
+	return !a.ExpiresAt.IsZero() && now.After(a.ExpiresAt)
+}
+
+// expiryLabel describes a deadline relative to now, e.g. "Free until Jan 14, 15:59 (2d 3h left)"
+func expiryLabel(a Asset, now time.Time) string {
+	if a.ExpiresAt.IsZero() {
+		return a.ExpiresText
+	}
+	local := a.ExpiresAt.Local()
+	if now.After(a.ExpiresAt) {
+		return "Expired " + local.Format("Jan 2")
+	}
+	return fmt.Sprintf("Free until %s (%s left)", local.Format("Jan 2, 15:04"), formatCountdown(a.ExpiresAt.Sub(now)))
+}
+
+// gameOfferLabel describes when a free game can be claimed, e.g. "Free from Jan 16, 17:00"
+func gameOfferLabel(a Asset, now time.Time) string {
+	if now.Before(a.StartsAt) {
+		return fmt.Sprintf("Free from %s (in %s)", a.StartsAt.Local().Format("Jan 2, 15:04"), formatCountdown(a.StartsAt.Sub(now)))
+	}
+	if label := expiryLabel(a, now); label != "" {
+		return label
+	}
+	return "Free now"
+}
+
+func formatCountdown(d time.Duration) string {
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	if days == 0 {
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dd %dh", days, hours)
+}
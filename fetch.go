@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Request layer shared by all scrapers: robots.txt checks, bounded retries
+// with jittered exponential backoff, Retry-After support and a per-host
+// token bucket.
+const (
+	fetchMaxAttempts   = 4
+	fetchBaseBackoff   = 2 * time.Second
+	fetchMaxBackoff    = 30 * time.Second
+	fetchMaxRetryAfter = 2 * time.Minute
+
+	hostRequestsPerSecond = 1.0
+	hostBurst             = 3.0
+)
+
+// Set when replaying recorded traffic, which doesn't need to be polite
+var rateLimitDisabled bool
+
+// newHTTPClient builds the client every scraper shares: conditional GETs
+// over the network through the configured proxy and CAs, or when configured
+// unconditional GETs with the traffic recorded, or replay of a recording
+func newHTTPClient() (*http.Client, error) {
+	timeout := time.Duration(config.TimeoutSeconds) * time.Second
+	if config.ReplayDir != "" {
+		replay, err := newReplayTransport(config.ReplayDir)
+		if err != nil {
+			return nil, err
+		}
+		rateLimitDisabled = true
+		return &http.Client{Timeout: timeout, Transport: replay}, nil
+	}
+
+	network, err := newNetworkTransport()
+	if err != nil {
+		return nil, err
+	}
+	// A recording has to hold every page in full: 304s to conditional GETs
+	// would replay as unchanged pages with nothing to reproduce
+	if config.RecordDir != "" {
+		rec, err := newRecordingTransport(network, config.RecordDir)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Client{
+			Timeout:   timeout,
+			Transport: &userAgentTransport{base: rec, userAgent: config.UserAgent},
+		}, nil
+	}
+	pageCache = newHTTPCache(network, filepath.Join(dataDir, httpCacheFileName))
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &userAgentTransport{base: pageCache, userAgent: config.UserAgent},
+	}, nil
+}
+
+// newNetworkTransport applies the proxy and extra root CAs from the config.
+// Without a configured proxy the usual HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+// environment variables apply.
+func newNetworkTransport() (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	proxy := httpproxy.FromEnvironment()
+	if config.ProxyURL != "" {
+		proxy.HTTPProxy, proxy.HTTPSProxy = config.ProxyURL, config.ProxyURL
+		if u, err := url.Parse(config.ProxyURL); err == nil {
+			log.Printf("Using proxy %s", u.Redacted())
+		}
+	}
+	if len(config.NoProxy) > 0 {
+		noProxy := config.NoProxy
+		if proxy.NoProxy != "" {
+			noProxy = append([]string{proxy.NoProxy}, noProxy...)
+		}
+		proxy.NoProxy = strings.Join(noProxy, ",")
+	}
+	proxyFunc := proxy.ProxyFunc()
+	t.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+
+	if len(config.CACertFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		for _, file := range config.CACertFiles {
+			pem, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("ca_cert_files: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("ca_cert_files: no PEM certificates in %s", file)
+			}
+		}
+		t.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return t, nil
+}
+
+// userAgentTransport identifies the app on every request
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// fetchError describes a request that failed after all its attempts
+type fetchError struct {
+	URL      string
+	Status   int
+	Attempts int
+	Err      error
+}
+
+func (e *fetchError) Error() string {
+	reason := fmt.Sprintf("status %d", e.Status)
+	if e.Err != nil {
+		reason = e.Err.Error()
+	}
+	if e.Attempts > 1 {
+		return fmt.Sprintf("%s: %s after %d attempts", e.URL, reason, e.Attempts)
+	}
+	return fmt.Sprintf("%s: %s", e.URL, reason)
+}
+
+func (e *fetchError) Unwrap() error { return e.Err }
+
+// fetchStats counts the requests made on behalf of one source in a check run
+type fetchStats struct {
+	mu       sync.Mutex
+	Requests int
+	Retries  int
+	Failures int
+}
+
+type fetchStatsKey struct{}
+
+// withFetchStats makes fetch record its outcomes in stats
+func withFetchStats(ctx context.Context, stats *fetchStats) context.Context {
+	return context.WithValue(ctx, fetchStatsKey{}, stats)
+}
+
+func recordFetch(ctx context.Context, attempts int, failed bool) {
+	stats, ok := ctx.Value(fetchStatsKey{}).(*fetchStats)
+	if !ok {
+		return
+	}
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	stats.Requests++
+	if attempts > 1 {
+		stats.Retries += attempts - 1
+	}
+	if failed {
+		stats.Failures++
+	}
+}
+
+// fetchDocument GETs pageURL and parses the response body as HTML. It
+// returns errNotModified if the page is unchanged since the last fetch.
+func fetchDocument(ctx context.Context, client *http.Client, pageURL string) (*goquery.Document, error) {
+	resp, err := fetch(ctx, client, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	// The final URL after redirects, for resolving relative links
+	doc.Url = resp.Request.URL
+	return doc, nil
+}
+
+// fetch GETs pageURL, retrying transient failures. On success the response
+// is a 200 and the caller must close its body.
+func fetch(ctx context.Context, client *http.Client, pageURL string) (*http.Response, error) {
+	resp, attempts, err := fetchWithRetry(ctx, client, pageURL)
+	if errors.Is(err, errRobotsDisallowed) || errors.Is(err, errRobotsUnreachable) {
+		// Never requested, so nothing to count
+		return nil, err
+	}
+	recordFetch(ctx, attempts, err != nil && err != errNotModified)
+	return resp, err
+}
+
+// fetchWithRetry does the work of fetch and also returns the number of attempts made
+func fetchWithRetry(ctx context.Context, client *http.Client, pageURL string) (*http.Response, int, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := checkRobots(ctx, client, u); err != nil {
+		return nil, 0, err
+	}
+	limiter := hostLimiter(u.Host)
+	if rateLimitDisabled {
+		limiter = nil
+	}
+
+	var lastStatus int
+	var lastErr error
+	for attempt := 1; attempt <= fetchMaxAttempts; attempt++ {
+		if err := limiter.wait(ctx); err != nil {
+			return nil, attempt - 1, &fetchError{URL: pageURL, Status: lastStatus, Attempts: attempt - 1, Err: err}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+		if err != nil {
+			return nil, attempt, err
+		}
+
+		resp, err := client.Do(req)
+		var delay time.Duration
+		var asked bool // the server said when to retry
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, attempt, &fetchError{URL: pageURL, Attempts: attempt, Err: ctx.Err()}
+			}
+			if !retryable(err) {
+				return nil, attempt, &fetchError{URL: pageURL, Attempts: attempt, Err: err}
+			}
+			lastErr, lastStatus = err, 0
+		case resp.StatusCode == http.StatusOK:
+			return resp, attempt, nil
+		case resp.StatusCode == http.StatusNotModified:
+			resp.Body.Close()
+			return nil, attempt, errNotModified
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			delay, asked = retryAfter(resp.Header.Get("Retry-After"), time.Now())
+			resp.Body.Close()
+			lastErr, lastStatus = nil, resp.StatusCode
+		default:
+			// Other 4xx won't get better by asking again
+			resp.Body.Close()
+			return nil, attempt, &fetchError{URL: pageURL, Status: resp.StatusCode, Attempts: attempt}
+		}
+
+		if attempt == fetchMaxAttempts {
+			break
+		}
+		if !asked {
+			delay = backoff(attempt)
+		}
+		if delay > fetchMaxRetryAfter {
+			// The server wants us gone for longer than a check lasts
+			return nil, attempt, &fetchError{URL: pageURL, Status: lastStatus, Attempts: attempt, Err: lastErr}
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, attempt, &fetchError{URL: pageURL, Status: lastStatus, Attempts: attempt, Err: ctx.Err()}
+		}
+	}
+	return nil, fetchMaxAttempts, &fetchError{URL: pageURL, Status: lastStatus, Attempts: fetchMaxAttempts, Err: lastErr}
+}
+
+// backoff returns the delay before retry number attempt: exponential with
+// ±50% jitter, capped at fetchMaxBackoff
+func backoff(attempt int) time.Duration {
+	d := fetchBaseBackoff << (attempt - 1)
+	if d > fetchMaxBackoff || d <= 0 {
+		d = fetchMaxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d)))
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP
+// date. ok is false if there's no valid header, so the usual backoff applies.
+func retryAfter(header string, now time.Time) (delay time.Duration, ok bool) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(header); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		if t.Before(now) {
+			return 0, true
+		}
+		return t.Sub(now), true
+	}
+	return 0, false
+}
+
+// retryable reports whether a request that got no response may succeed if
+// sent again: timeouts and connections dropped mid-request may, refused
+// connections, DNS and certificate errors won't within a check
+func retryable(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	// Resets are reported as failed reads on every platform
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "read"
+}
+
+// resolveHref turns a link found on doc into an absolute, canonical URL
+func resolveHref(doc *goquery.Document, href string) string {
+	ref, err := url.Parse(strings.TrimSpace(href))
+	if err != nil || doc.Url == nil {
+		return href
+	}
+	return canonicalURL(doc.Url.ResolveReference(ref).String())
+}
+
+// tokenBucket spaces out requests to one host
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+var (
+	hostLimitersMu sync.Mutex
+	hostLimiters   = make(map[string]*tokenBucket)
+)
+
+func hostLimiter(host string) *tokenBucket {
+	hostLimitersMu.Lock()
+	defer hostLimitersMu.Unlock()
+	b, ok := hostLimiters[host]
+	if !ok {
+		b = &tokenBucket{rate: hostRequestsPerSecond, burst: hostBurst, tokens: hostBurst, last: time.Now()}
+		hostLimiters[host] = b
+	}
+	return b
+}
+
+// slowTo spaces requests at least interval apart, for a robots.txt Crawl-delay
+func (b *tokenBucket) slowTo(interval time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if rate := 1 / interval.Seconds(); rate < b.rate {
+		b.rate, b.burst = rate, 1
+		if b.tokens > 1 {
+			b.tokens = 1
+		}
+	}
+}
+
+// wait blocks until a token is available or ctx is done. A nil bucket
+// never blocks.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	if b == nil {
+		return ctx.Err()
+	}
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
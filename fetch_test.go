@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fetchStep is one scripted response: a status with an optional Retry-After,
+// or a connection dropped before any response
+type fetchStep struct {
+	status     int
+	retryAfter string
+	drop       bool
+}
+
+func TestFetchWithRetry(t *testing.T) {
+	savedLimit := rateLimitDisabled
+	rateLimitDisabled = true
+	t.Cleanup(func() { rateLimitDisabled = savedLimit })
+
+	tests := []struct {
+		name     string
+		steps    []fetchStep
+		attempts int
+		status   int // of the fetchError, 0 on success
+	}{
+		{"503 with Retry-After, 429, then 200", []fetchStep{{status: 503, retryAfter: "0"}, {status: 429, retryAfter: "0"}, {status: 200}}, 3, 0},
+		{"dropped connection, then 200", []fetchStep{{drop: true}, {status: 200}}, 2, 0},
+		{"404 isn't retried", []fetchStep{{status: 404}}, 1, 404},
+		{"503 on every attempt", []fetchStep{{status: 503, retryAfter: "0"}}, fetchMaxAttempts, 503},
+		{"Retry-After longer than a check", []fetchStep{{status: 503, retryAfter: "3600"}}, 1, 503},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			requests := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/robots.txt" {
+					http.NotFound(w, r)
+					return
+				}
+				mu.Lock()
+				step := tt.steps[min(requests, len(tt.steps)-1)]
+				requests++
+				mu.Unlock()
+				if step.drop {
+					conn, _, _ := w.(http.Hijacker).Hijack()
+					conn.Close()
+					return
+				}
+				if step.retryAfter != "" {
+					w.Header().Set("Retry-After", step.retryAfter)
+				}
+				w.WriteHeader(step.status)
+			}))
+			defer srv.Close()
+
+			// Without keep-alives the transport can't quietly resend a
+			// request whose connection was dropped
+			client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+			resp, attempts, err := fetchWithRetry(context.Background(), client, srv.URL+"/page")
+			if resp != nil {
+				resp.Body.Close()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if attempts != tt.attempts || requests != tt.attempts {
+				t.Errorf("%d attempts, %d requests; want %d", attempts, requests, tt.attempts)
+			}
+			var fe *fetchError
+			switch {
+			case tt.status == 0 && err != nil:
+				t.Errorf("failed: %v", err)
+			case tt.status != 0 && (!errors.As(err, &fe) || fe.Status != tt.status):
+				t.Errorf("error %v, want status %d", err, tt.status)
+			}
+		})
+	}
+}
+
+func TestFetchWithRetryStopsWhenCancelled(t *testing.T) {
+	savedLimit := rateLimitDisabled
+	rateLimitDisabled = true
+	t.Cleanup(func() { rateLimitDisabled = savedLimit })
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, attempts, err := fetchWithRetry(ctx, srv.Client(), srv.URL+"/page")
+	if attempts != 1 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("%d attempts, error %v; want 1 attempt ending in the deadline", attempts, err)
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{timeoutError{}, true},
+		{fmt.Errorf("get: %w", io.EOF), true},
+		{io.ErrUnexpectedEOF, true},
+		{&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("wsarecv", errors.New("connection reset"))}, true},
+		{os.NewSyscallError("write", syscall.ECONNRESET), true},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, false},
+		{&net.DNSError{Err: "no such host", Name: "example.invalid"}, false},
+		{errors.New("x509: certificate signed by unknown authority"), false},
+	}
+	for _, tt := range tests {
+		if got := retryable(tt.err); got != tt.want {
+			t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 7, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		delay  time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"0", 0, true},
+		{" 120 ", 2 * time.Minute, true},
+		{"-5", 0, false},
+		{"soon", 0, false},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{now.Add(-time.Hour).Format(http.TimeFormat), 0, true},
+	}
+	for _, tt := range tests {
+		delay, ok := retryAfter(tt.header, now)
+		if delay != tt.delay || ok != tt.ok {
+			t.Errorf("retryAfter(%q) = %v, %v; want %v, %v", tt.header, delay, ok, tt.delay, tt.ok)
+		}
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	for attempt := 1; attempt <= 6; attempt++ {
+		d := fetchBaseBackoff << (attempt - 1)
+		if d > fetchMaxBackoff {
+			d = fetchMaxBackoff
+		}
+		for i := 0; i < 100; i++ {
+			if got := backoff(attempt); got < d/2 || got >= d/2+d {
+				t.Fatalf("backoff(%d) = %v, want within [%v, %v)", attempt, got, d/2, d/2+d)
+			}
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	b := &tokenBucket{rate: 20, burst: 2, tokens: 2, last: time.Now()}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := b.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The burst goes at once, the third request waits for a token
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("3 requests at 20/s with a burst of 2 took %v, want about 50ms", elapsed)
+	}
+
+	b.slowTo(time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := b.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wait on an empty bucket returned %v, want the deadline", err)
+	}
+
+	var unlimited *tokenBucket
+	if err := unlimited.wait(context.Background()); err != nil {
+		t.Errorf("nil bucket: %v", err)
+	}
+}
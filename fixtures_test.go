@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Golden-file tests for the scrapers. Saved pages under testdata/fixtures
+// are served from a local httptest server, the scrapers run against them,
+// and their output is compared with testdata/golden. After an intended
+// change in scraper output, rerun with -update and review the golden diff:
+//
+//	go test -run TestGolden -update
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files with the current output")
+
+// fixtureBase is replaced by the fixture server's URL in served pages and
+// put back in golden output, so goldens don't depend on the port
+const fixtureBase = "{{BASE}}"
+
+// Reference time for parsing fixture dates without a year
+var fixtureRef = time.Date(2025, 1, 7, 15, 0, 0, 0, time.UTC)
+
+// fixtureCheck produces the output for one golden file
+type fixtureCheck struct {
+	golden string
+	run    func(dir string) (interface{}, error)
+}
+
+var fixtureChecks = []fixtureCheck{
+	{"unrealsource.json", unrealSourceFixture},
+	{"epicgames.json", epicGamesFixture},
+	{"fabsearch.json", fabSearchFixture},
+	{"listings.json", listingsFixture},
+	{"articles.json", articlesFixture},
+	{"expiry.json", expiryFixture},
+	{"reldates.json", relativeDateFixture},
+	{"canonical.json", canonicalURLFixture},
+	{"robots.json", robotsFixture},
+	{"datafiles.json", dataFileFixture},
+	{"lifecycle.json", lifecycleFixture},
+}
+
+func TestGolden(t *testing.T) {
+	for _, check := range fixtureChecks {
+		check := check
+		t.Run(strings.TrimSuffix(check.golden, ".json"), func(t *testing.T) {
+			out, err := check.run(filepath.Join("testdata", "fixtures"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(out); err != nil {
+				t.Fatal(err)
+			}
+			got := buf.Bytes()
+
+			goldenPath := filepath.Join("testdata", "golden", check.golden)
+			if *updateGolden {
+				if err := os.WriteFile(goldenPath, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if diff := firstDifference(want, got); diff != "" {
+				t.Errorf("%s differs (rerun with -update if the change is intended)\n%s", goldenPath, diff)
+			}
+		})
+	}
+}
+
+// firstDifference describes the first line where want and got differ
+func firstDifference(want, got []byte) string {
+	if bytes.Equal(want, got) {
+		return ""
+	}
+	wantLines := strings.Split(string(want), "\n")
+	gotLines := strings.Split(string(got), "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return fmt.Sprintf("  line %d:\n    want: %s\n    got:  %s", i+1, w, g)
+		}
+	}
+	return ""
+}
+
+// newFixtureServer serves the files under dir, with directories served by
+// their index.html and {{BASE}} replaced by the server's own URL
+func newFixtureServer(dir string) *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := filepath.Join(dir, filepath.FromSlash(path.Clean(r.URL.Path)))
+		if info, err := os.Stat(name); err == nil && info.IsDir() {
+			name = filepath.Join(name, "index.html")
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(bytes.ReplaceAll(data, []byte(fixtureBase), []byte(srv.URL)))
+	}))
+	return srv
+}
+
+// withFixtureServer runs fn against a fixture server and puts {{BASE}} back
+// into its JSON-encoded output
+func withFixtureServer(dir string, fn func(srv *httptest.Server) (interface{}, error)) (interface{}, error) {
+	srv := newFixtureServer(dir)
+	defer srv.Close()
+
+	saved := rateLimitDisabled
+	rateLimitDisabled = true
+	defer func() { rateLimitDisabled = saved }()
+
+	out, err := fn(srv)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.ReplaceAll(data, []byte(srv.URL), []byte(fixtureBase))
+	var normalized interface{}
+	err = json.Unmarshal(data, &normalized)
+	return normalized, err
+}
+
+type unrealSourceOutput struct {
+	Free        []Asset        `json:"free"`
+	Latest      []Asset        `json:"latest"`
+	Batches     []Batch        `json:"batches"`
+	RuleMatches map[string]int `json:"rule_matches"`
+	Errors      []string       `json:"errors"`
+}
+
+func unrealSourceFixture(dir string) (interface{}, error) {
+	return withFixtureServer(filepath.Join(dir, "unrealsource"), func(srv *httptest.Server) (interface{}, error) {
+		src := newUnrealSource(srv.URL+"/dispatch/", srv.Client())
+		src.now = func() time.Time { return fixtureRef }
+		result, err := src.Fetch(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		out := unrealSourceOutput{
+			Free:        result.Free,
+			Latest:      result.Latest,
+			Batches:     result.Batches,
+			RuleMatches: result.RuleMatches,
+			Errors:      []string{},
+		}
+		for _, e := range result.Errors {
+			out.Errors = append(out.Errors, e.Error())
+		}
+		return out, nil
+	})
+}
+
+func epicGamesFixture(dir string) (interface{}, error) {
+	return withFixtureServer(filepath.Join(dir, "epicgames"), func(srv *httptest.Server) (interface{}, error) {
+		src := newEpicGamesSource(srv.URL+"/promotions.json", srv.Client())
+		src.now = func() time.Time { return fixtureRef }
+		result, err := src.Fetch(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		return result.Games, nil
+	})
+}
+
+func fabSearchFixture(dir string) (interface{}, error) {
+	return withFixtureServer(filepath.Join(dir, "fabsearch"), func(srv *httptest.Server) (interface{}, error) {
+		result, err := newFabSearchSource(srv.URL+"/search.json", srv.Client()).Fetch(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		return result.Permanent, nil
+	})
+}
+
+func listingsFixture(dir string) (interface{}, error) {
+	out := make(map[string]*listingDetails)
+	err := eachFixturePage(filepath.Join(dir, "listings"), func(name string, doc *goquery.Document) {
+		out[name] = parseListingPage(doc)
+	})
+	return out, err
+}
+
+func articlesFixture(dir string) (interface{}, error) {
+	out := make(map[string]*articleDetails)
+	err := eachFixturePage(filepath.Join(dir, "articles"), func(name string, doc *goquery.Document) {
+		out[name] = parseArticlePage(doc)
+	})
+	return out, err
+}
+
+// eachFixturePage parses every .html file in dir, in name order
+func eachFixturePage(dir string, fn func(name string, doc *goquery.Document)) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		fn(filepath.Base(f), doc)
+	}
+	return nil
+}
+
+type expiryCase struct {
+	Text    string    `json:"text"`
+	Found   bool      `json:"found"`
+	Expires time.Time `json:"expires"`
+	Display string    `json:"display,omitempty"`
+}
+
+func expiryFixture(dir string) (interface{}, error) {
+	f, err := os.Open(filepath.Join(dir, "expiry.txt"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []expiryCase
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		at, display, ok := parseExpiry(line, fixtureRef)
+		out = append(out, expiryCase{Text: line, Found: ok, Expires: at, Display: display})
+	}
+	return out, scanner.Err()
+}
+
+type relativeDateCase struct {
+	Text      string    `json:"text"`
+	Found     bool      `json:"found"`
+	Published time.Time `json:"published"`
+	Precision string    `json:"precision,omitempty"`
+}
+
+func relativeDateFixture(dir string) (interface{}, error) {
+	f, err := os.Open(filepath.Join(dir, "reldates.txt"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []relativeDateCase
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		at, precision, ok := parseRelativeTime(line, fixtureRef)
+		out = append(out, relativeDateCase{Text: line, Found: ok, Published: at, Precision: precision})
+	}
+	return out, scanner.Err()
+}
+
+// canonicalURLFixture maps every URL in urls.txt to its canonical form
+func canonicalURLFixture(dir string) (interface{}, error) {
+	f, err := os.Open(filepath.Join(dir, "urls.txt"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	out := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		out[line] = canonicalURL(line)
+	}
+	return out, scanner.Err()
+}
+
+type robotsCase struct {
+	CrawlDelay string          `json:"crawl_delay,omitempty"`
+	Allowed    map[string]bool `json:"allowed"`
+}
+
+// robotsFixture checks every path in robots/paths.txt against each robots
+// file, as read for our default User-Agent
+func robotsFixture(dir string) (interface{}, error) {
+	robotsDir := filepath.Join(dir, "robots")
+	raw, err := os.ReadFile(filepath.Join(robotsDir, "paths.txt"))
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, line := range strings.Split(string(raw), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			paths = append(paths, line)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(robotsDir, "*.txt"))
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]robotsCase)
+	for _, file := range files {
+		name := filepath.Base(file)
+		if name == "paths.txt" {
+			continue
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		policy := parseRobots(f, "unrealfreeassets")
+		f.Close()
+
+		c := robotsCase{Allowed: make(map[string]bool)}
+		if policy.crawlDelay > 0 {
+			c.CrawlDelay = policy.crawlDelay.String()
+		}
+		for _, p := range paths {
+			c.Allowed[p] = policy.allowed(p)
+		}
+		out[name] = c
+	}
+	return out, nil
+}
+
+type dataFileCase struct {
+	Version  int      `json:"version"`
+	Problems []string `json:"problems"`
+	Assets   []Asset  `json:"assets"`
+}
+
+// dataFileFixture runs the data file check on each damaged file in
+// datafiles/ and records the problems found and the assets repair keeps
+func dataFileFixture(dir string) (interface{}, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "datafiles", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]dataFileCase)
+	for _, file := range files {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		c := checkDataFile(raw, fixtureRef)
+		data, err := c.repaired()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		result := dataFileCase{Version: c.version, Problems: []string{}, Assets: []Asset{}}
+		for _, p := range c.problems {
+			result.Problems = append(result.Problems, fmt.Sprintf("%s: %s (%s)", p.where, p.problem, p.fix))
+		}
+		for _, a := range data.SeenAssets {
+			result.Assets = append(result.Assets, a)
+		}
+		sort.Slice(result.Assets, func(i, j int) bool { return result.Assets[i].URL < result.Assets[j].URL })
+		out[filepath.Base(file)] = result
+	}
+	return out, nil
+}
+
+// lifecycleStep is one source's part of a check: what it returned, and when
+type lifecycleStep struct {
+	Time   time.Time   `json:"time"`
+	Source string      `json:"source"`
+	Result FetchResult `json:"result"`
+}
+
+type lifecycleCase struct {
+	URL      string       `json:"url"`
+	LastSeen time.Time    `json:"last_seen"`
+	History  []AssetEvent `json:"history"`
+}
+
+// lifecycleFixture replays each file in lifecycle/ as a series of checks,
+// recording history the way runCheck does, and keeps the history of every
+// asset
+func lifecycleFixture(dir string) (interface{}, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "lifecycle", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string][]lifecycleCase)
+	for _, file := range files {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var steps []lifecycleStep
+		if err := json.Unmarshal(raw, &steps); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		d := &AppData{SeenAssets: make(map[string]Asset)}
+		for _, step := range steps {
+			r := &step.Result
+			recordExpiries(d, step.Time)
+			for _, assets := range [][]Asset{r.Free, r.Games, r.Permanent, r.Latest} {
+				mergeAssets(d, step.Source, assets, step.Time)
+			}
+			recordListing(d, step.Source, r, step.Time)
+			mergeBatches(d, r.Batches)
+		}
+
+		cases := []lifecycleCase{}
+		for _, a := range d.SeenAssets {
+			cases = append(cases, lifecycleCase{URL: a.URL, LastSeen: a.LastSeen, History: a.History})
+		}
+		sort.Slice(cases, func(i, j int) bool { return cases[i].URL < cases[j].URL })
+		out[filepath.Base(file)] = cases
+	}
+	return out, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Breakage detection: every run's yield is compared with the source's
+// recent history, so a layout change that makes the scraper come back empty
+// shows up as "broken" instead of looking like a quiet day.
+const (
+	yieldHistorySize = 30
+	// Runs needed before yield is compared with the baseline
+	yieldMinSamples = 3
+	// A run finding less than this fraction of the baseline is suspicious
+	yieldDropRatio = 0.3
+)
+
+// YieldSample records what one run of a source found
+type YieldSample struct {
+	Time        time.Time      `json:"time"`
+	Found       int            `json:"found"`
+	RuleMatches map[string]int `json:"rule_matches,omitempty"`
+	// Some pages were unchanged and skipped, so Found undercounts
+	Partial bool `json:"partial,omitempty"`
+}
+
+// recordYield adds a run to the source's history and returns why the source
+// looks broken, or "" if the run looks normal
+func recordYield(d *AppData, name string, result *FetchResult) string {
+	if d.YieldHistory == nil {
+		d.YieldHistory = make(map[string][]YieldSample)
+	}
+	sample := YieldSample{
+		Time:        time.Now(),
+		Found:       result.found(),
+		RuleMatches: result.RuleMatches,
+		Partial:     len(result.UnchangedPages) > 0,
+	}
+
+	history := d.YieldHistory[name]
+	reason := checkYield(history, sample)
+
+	history = append(history, sample)
+	if len(history) > yieldHistorySize {
+		history = history[len(history)-yieldHistorySize:]
+	}
+	d.YieldHistory[name] = history
+	return reason
+}
+
+// checkYield compares a run with the runs before it
+func checkYield(history []YieldSample, sample YieldSample) string {
+	if sample.Found == 0 && !sample.Partial {
+		return "found nothing"
+	}
+
+	// A rule that matched in most earlier runs and now matches nothing
+	// means its selectors no longer fit the page
+	var rules []string
+	for rule := range sample.RuleMatches {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	for _, rule := range rules {
+		if sample.RuleMatches[rule] > 0 || len(history) < yieldMinSamples {
+			continue
+		}
+		matched := 0
+		for _, h := range history {
+			if h.RuleMatches[rule] > 0 {
+				matched++
+			}
+		}
+		if matched*2 > len(history) {
+			return fmt.Sprintf("rule %q matched nothing", rule)
+		}
+	}
+
+	if sample.Partial {
+		return ""
+	}
+	var counts []int
+	for _, h := range history {
+		if !h.Partial {
+			counts = append(counts, h.Found)
+		}
+	}
+	if len(counts) < yieldMinSamples {
+		return ""
+	}
+	baseline := median(counts)
+	if float64(sample.Found) < float64(baseline)*yieldDropRatio {
+		return fmt.Sprintf("found %d items, usually %d", sample.Found, baseline)
+	}
+	return ""
+}
+
+func median(values []int) int {
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	return sorted[len(sorted)/2]
+}
+
+// brokenSources lists sources whose last run looked broken, with the reason
+func brokenSources(d *AppData) []string {
+	var broken []string
+	for name, st := range d.Sources {
+		if st.Broken != "" {
+			broken = append(broken, fmt.Sprintf("%s (%s)", name, st.Broken))
+		}
+	}
+	sort.Strings(broken)
+	return broken
+}
+
+// failingSources lists sources whose last fetch reported errors
+func failingSources(d *AppData) []string {
+	var failing []string
+	for name, st := range d.Sources {
+		if len(st.Errors) > 0 {
+			failing = append(failing, name)
+		}
+	}
+	sort.Strings(failing)
+	return failing
+}
+
+// robotsWarnedSources lists sources whose last fetch skipped pages because of robots.txt
+func robotsWarnedSources(d *AppData) []string {
+	var warned []string
+	for name, st := range d.Sources {
+		if len(st.Warnings) > 0 {
+			warned = append(warned, name)
+		}
+	}
+	sort.Strings(warned)
+	return warned
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const httpCacheFileName = "http_cache.json"
+
+// errNotModified is returned by fetchDocument when the server answered a
+// conditional GET with 304, i.e. the page hasn't changed since last time
+var errNotModified = errors.New("not modified")
+
+// httpCacheEntry holds the validators of the last 200 response for a URL
+type httpCacheEntry struct {
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Stored       time.Time `json:"stored"`
+}
+
+// httpCache is a RoundTripper that remembers ETag/Last-Modified per URL in
+// the data dir and turns GETs into conditional requests
+type httpCache struct {
+	base http.RoundTripper
+	path string
+
+	mu      sync.Mutex
+	entries map[string]httpCacheEntry
+}
+
+type noHTTPCacheKey struct{}
+
+// withoutHTTPCache marks requests made with ctx as unconditional, for callers
+// that need the page body even if it hasn't changed
+func withoutHTTPCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noHTTPCacheKey{}, true)
+}
+
+// cacheUpdates collects the validators received during one source's fetch.
+// They're only worth keeping once what was scraped from those pages is
+// saved: until then a 304 would hide pages whose results were lost.
+type cacheUpdates struct {
+	mu      sync.Mutex
+	entries map[string]httpCacheEntry
+}
+
+type cacheUpdatesKey struct{}
+
+// withCacheUpdates makes the cache hold validators of responses to requests
+// made with ctx in updates instead of storing them
+func withCacheUpdates(ctx context.Context, updates *cacheUpdates) context.Context {
+	return context.WithValue(ctx, cacheUpdatesKey{}, updates)
+}
+
+func newHTTPCache(base http.RoundTripper, path string) *httpCache {
+	c := &httpCache{base: base, path: path, entries: make(map[string]httpCacheEntry)}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &c.entries); err != nil {
+			log.Printf("HTTP cache unreadable, starting empty: %v", err)
+			c.entries = make(map[string]httpCacheEntry)
+		}
+	}
+	return c
+}
+
+func (c *httpCache) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" || req.Context().Value(noHTTPCacheKey{}) != nil {
+		return c.base.RoundTrip(req)
+	}
+
+	key := req.URL.String()
+	c.mu.Lock()
+	entry, cached := c.entries[key]
+	c.mu.Unlock()
+
+	if cached && req.Header.Get("If-None-Match") == "" && req.Header.Get("If-Modified-Since") == "" {
+		req = req.Clone(req.Context())
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := c.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			entry := httpCacheEntry{ETag: etag, LastModified: lastModified, Stored: time.Now()}
+			if updates, ok := req.Context().Value(cacheUpdatesKey{}).(*cacheUpdates); ok {
+				updates.mu.Lock()
+				if updates.entries == nil {
+					updates.entries = make(map[string]httpCacheEntry)
+				}
+				updates.entries[key] = entry
+				updates.mu.Unlock()
+			} else {
+				c.store(key, entry)
+			}
+		}
+	}
+	return resp, nil
+}
+
+func (c *httpCache) store(key string, entry httpCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = entry
+	c.saveLocked()
+}
+
+// commit stores validators collected with withCacheUpdates
+func (c *httpCache) commit(updates *cacheUpdates) {
+	if c == nil {
+		return
+	}
+	updates.mu.Lock()
+	defer updates.mu.Unlock()
+	if len(updates.entries) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, entry := range updates.entries {
+		c.entries[key] = entry
+	}
+	c.saveLocked()
+}
+
+// clear forgets all validators, so the next fetch of every page is a full one
+func (c *httpCache) clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]httpCacheEntry)
+	c.saveLocked()
+}
+
+func (c *httpCache) saveLocked() {
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		log.Printf("HTTP cache error: %v", err)
+		return
+	}
+	if err := writeFileAtomic(c.path, data, 0644); err != nil {
+		log.Printf("HTTP cache error: %v", err)
+	}
+}
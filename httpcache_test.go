@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestHTTPCacheKeepsValidatorsUntilCommit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("<html></html>"))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), httpCacheFileName)
+	cache := newHTTPCache(http.DefaultTransport, path)
+	client := &http.Client{Transport: cache}
+	get := func(ctx context.Context) int {
+		t.Helper()
+		req, err := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	updates := &cacheUpdates{}
+	if status := get(withCacheUpdates(context.Background(), updates)); status != http.StatusOK {
+		t.Fatalf("first fetch: status %d", status)
+	}
+	// Results not saved yet: the page must be fetched in full again
+	if status := get(withCacheUpdates(context.Background(), &cacheUpdates{})); status != http.StatusOK {
+		t.Errorf("fetch before commit: status %d, want 200", status)
+	}
+
+	cache.commit(updates)
+	if status := get(context.Background()); status != http.StatusNotModified {
+		t.Errorf("fetch after commit: status %d, want 304", status)
+	}
+	// The committed validators survive a restart
+	client.Transport = newHTTPCache(http.DefaultTransport, path)
+	if status := get(context.Background()); status != http.StatusNotModified {
+		t.Errorf("fetch after reload: status %d, want 304", status)
+	}
+}
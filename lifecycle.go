@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Asset lifecycle: every check records what happened to the assets it
+// saw, or stopped seeing, in their history. Absence only counts where the
+// source lists everything it offers (Epic's promotions) or for a dispatch
+// article that was fetched again and no longer lists an asset; Fab search
+// only shows its first page, so an asset missing from it means nothing.
+const (
+	eventAppeared   = "appeared"
+	eventChanged    = "changed"
+	eventExpired    = "expired"
+	eventRemoved    = "removed"
+	eventReappeared = "reappeared"
+)
+
+// Events kept per asset; the oldest after the first are dropped
+const maxAssetHistory = 50
+
+// AssetEvent is one entry in an asset's history
+type AssetEvent struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+	// For changes, the field and its old and new values; for an asset
+	// free again in a later batch, that batch's article
+	Field string `json:"field,omitempty"`
+	From  string `json:"from,omitempty"`
+	To    string `json:"to,omitempty"`
+}
+
+// addEvent appends an event to the asset's history. Assets saved before
+// histories were kept get their first sighting as the first event.
+func (a *Asset) addEvent(e AssetEvent) {
+	if len(a.History) == 0 && e.Type != eventAppeared && !a.FirstSeen.IsZero() {
+		a.History = append(a.History, AssetEvent{Time: a.FirstSeen, Type: eventAppeared})
+	}
+	a.History = append(a.History, e)
+	if len(a.History) > maxAssetHistory {
+		// Copies of the asset handed to the UI share the old array, so the
+		// trimmed history goes into a new one
+		trimmed := make([]AssetEvent, 0, maxAssetHistory)
+		trimmed = append(trimmed, a.History[0])
+		a.History = append(trimmed, a.History[len(a.History)-maxAssetHistory+1:]...)
+	}
+}
+
+// lastEvent is the asset's latest event, or "" if it has none
+func lastEvent(a Asset) string {
+	if len(a.History) == 0 {
+		return ""
+	}
+	return a.History[len(a.History)-1].Type
+}
+
+// isListed reports whether the asset was on offer when last checked: not
+// removed by its source and not expired
+func isListed(a Asset) bool {
+	last := lastEvent(a)
+	return last != eventRemoved && last != eventExpired
+}
+
+// newAsset prepares an asset seen for the first time
+func newAsset(a Asset, source string, now time.Time) Asset {
+	a.Source = source
+	a.FirstSeen, a.LastSeen = now, now
+	a.History = []AssetEvent{{Time: now, Type: eventAppeared}}
+	return a
+}
+
+// observeAsset records another sighting of a known asset: it comes back if
+// it was gone, and changes to its price, title or dates are recorded and
+// applied
+func observeAsset(existing, seen Asset, source string, now time.Time) Asset {
+	a := existing
+	a.Source = source
+	a.LastSeen = now
+
+	// A free asset listed again in an older batch than the one it's filed
+	// under carries that batch's dates, which are out of date
+	newBatch := seen.DispatchURL != "" && seen.DispatchURL != existing.DispatchURL
+	if newBatch && !seen.Announced.After(existing.Announced) {
+		if lastEvent(a) == eventRemoved {
+			a.addEvent(AssetEvent{Time: now, Type: eventReappeared})
+		}
+		return a
+	}
+
+	back := AssetEvent{Time: now, Type: eventReappeared}
+	if newBatch {
+		back.To = seen.DispatchURL
+		a.DispatchURL, a.Announced = seen.DispatchURL, seen.Announced
+	}
+	switch lastEvent(a) {
+	case eventRemoved:
+		a.addEvent(back)
+	case eventExpired:
+		// Free again in a later batch or promotion
+		if newBatch || (seen.ExpiresAt.After(existing.ExpiresAt) && !isExpired(seen, now)) {
+			a.addEvent(back)
+		}
+	}
+
+	change := func(field, from, to string) {
+		a.addEvent(AssetEvent{Time: now, Type: eventChanged, Field: field, From: from, To: to})
+	}
+	// News titles are link text until the article's own title is fetched
+	if seen.Title != "" && seen.Title != a.Title && a.Category != CategoryLatest {
+		change("title", a.Title, seen.Title)
+		a.Title = seen.Title
+	}
+	if seen.Price != "" && seen.Price != a.Price {
+		change("price", a.Price, seen.Price)
+		a.Price = seen.Price
+	}
+	if !seen.ExpiresAt.IsZero() && !seen.ExpiresAt.Equal(a.ExpiresAt) {
+		change("expires_at", eventTime(a.ExpiresAt), eventTime(seen.ExpiresAt))
+		a.ExpiresAt, a.ExpiresText = seen.ExpiresAt, seen.ExpiresText
+	}
+	if !seen.StartsAt.IsZero() && !seen.StartsAt.Equal(a.StartsAt) {
+		change("starts_at", eventTime(a.StartsAt), eventTime(seen.StartsAt))
+		a.StartsAt = seen.StartsAt
+	}
+	return a
+}
+
+// eventTime formats a date for a change event
+func eventTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// recordExpiries marks offers whose deadline passed as expired, as of
+// their deadline
+func recordExpiries(d *AppData, now time.Time) {
+	for key, a := range d.SeenAssets {
+		if isExpired(a, now) && isListed(a) {
+			a.addEvent(AssetEvent{Time: a.ExpiresAt, Type: eventExpired})
+			d.SeenAssets[key] = a
+		}
+	}
+}
+
+// recordListing records what a source's listing says beyond the assets in
+// it, before its batches are merged: assets on pages that were unchanged
+// since the last check are still listed, and assets missing from a
+// complete listing or from a refetched article were removed
+func recordListing(d *AppData, source string, result *FetchResult, now time.Time) {
+	status := d.Sources[source]
+
+	// Unchanged pages list what they listed last time
+	stillListed := func(key string) {
+		if a, ok := d.SeenAssets[key]; ok && isListed(a) {
+			a.LastSeen = now
+			d.SeenAssets[key] = a
+		}
+	}
+	if result.NotModified {
+		for key, a := range d.SeenAssets {
+			if a.Source == source && a.LastSeen.Equal(status.Listed) {
+				stillListed(key)
+			}
+		}
+	}
+	for _, page := range result.UnchangedPages {
+		for _, key := range d.Batches[page].AssetURLs {
+			stillListed(key)
+		}
+	}
+
+	removed := func(key string) {
+		if a, ok := d.SeenAssets[key]; ok && isListed(a) && !isExpired(a, now) {
+			a.addEvent(AssetEvent{Time: now, Type: eventRemoved})
+			d.SeenAssets[key] = a
+		}
+	}
+	if result.Complete && !result.NotModified {
+		listed := make(map[string]bool)
+		for _, assets := range [][]Asset{result.Free, result.Latest, result.Games, result.Permanent} {
+			for _, a := range assets {
+				listed[canonicalURL(a.URL)] = true
+			}
+		}
+		for key, a := range d.SeenAssets {
+			if a.Source == source && !listed[key] {
+				removed(key)
+			}
+		}
+	}
+	for _, b := range result.Batches {
+		for _, key := range d.Batches[b.ArticleURL].AssetURLs {
+			// Only the article an asset is filed under can take it away
+			if !containsString(b.AssetURLs, key) && d.SeenAssets[key].DispatchURL == b.ArticleURL {
+				removed(key)
+			}
+		}
+	}
+
+	status.Listed = now
+	setSourceStatus(d, source, status)
+}
+
+// eventLabel describes a history event, e.g. "Free until changed from
+// Jan 7, 15:59 to Jan 14, 15:59"
+func eventLabel(e AssetEvent) string {
+	switch e.Type {
+	case eventAppeared:
+		return "First seen"
+	case eventExpired:
+		return "Offer expired"
+	case eventRemoved:
+		return "No longer listed by its source"
+	case eventReappeared:
+		if e.To != "" {
+			return "Free again in a later batch"
+		}
+		return "Listed again"
+	case eventChanged:
+		from, to := eventValue(e.From), eventValue(e.To)
+		if from == "" {
+			return fmt.Sprintf("%s set to %s", fieldLabel(e.Field), to)
+		}
+		return fmt.Sprintf("%s changed from %s to %s", fieldLabel(e.Field), from, to)
+	}
+	return e.Type
+}
+
+func fieldLabel(field string) string {
+	switch field {
+	case "title":
+		return "Title"
+	case "price":
+		return "Price"
+	case "category":
+		return "Category"
+	case "expires_at":
+		return "Free until"
+	case "starts_at":
+		return "Free from"
+	}
+	return field
+}
+
+// eventValue shows dates from change events in local time
+func eventValue(v string) string {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.Local().Format("Jan 2, 15:04")
+	}
+	return v
+}
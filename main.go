@@ -1,7 +1,7 @@
 package main
 
 import (
-	"encoding/json"
+	"flag"
 	"fmt"
 	"image/color"
 	"log"
@@ -9,8 +9,6 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
-	"sort"
 	"strings"
 	"time"
 
@@ -19,55 +17,99 @@ import (
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
-	"github.com/PuerkitoBio/goquery"
 	"github.com/go-toast/toast"
 )
 
 const (
-	unrealSourceURL = "https://unrealsource.com/dispatch/"
-	checkInterval   = 1 * time.Hour
-	dataFileName    = "seen_assets.json"
+	checkInterval    = 1 * time.Hour
+	checkTimeout     = 5 * time.Minute
+	backfillTimeout  = 30 * time.Minute
+	backfillPages    = 50
+	enrichStageName  = "fab-listings"
+	articleStageName = "dispatch-articles"
+	dataFileName     = "seen_assets.json"
 )
 
 // Asset categories
 const (
 	CategoryFree   = "free"
 	CategoryLatest = "latest"
+	CategoryGames  = "games" // Epic Games Store weekly free games
+	// Fab listings that are free for good, not just for a promotion
+	CategoryPermanent = "permanent"
 )
 
 type Asset struct {
 	Title     string    `json:"title"`
 	URL       string    `json:"url"`
 	Price     string    `json:"price"`
-	Category  string    `json:"category"` // "free" or "latest"
-	ExpiresAt string    `json:"expires_at,omitempty"`
+	Category  string    `json:"category"` // "free", "latest", "games" or "permanent"
 	FirstSeen time.Time `json:"first_seen"`
+
+	// Deadline of a free offer, and the phrase it was parsed from
+	ExpiresAt   time.Time `json:"expires_at"`
+	ExpiresText string    `json:"expires_text,omitempty"`
+	// Start of a free offer announced ahead of time
+	StartsAt time.Time `json:"starts_at"`
+
+	// Dispatch article that announced a free asset, and when it was published
+	DispatchURL string    `json:"dispatch_url,omitempty"`
+	Announced   time.Time `json:"announced"`
+
+	// Listing details, filled in by enrichAssets from the fab.com page
+	Seller          string    `json:"seller,omitempty"`
+	ListingCategory string    `json:"listing_category,omitempty"`
+	OriginalPrice   string    `json:"original_price,omitempty"`
+	EngineVersions  []string  `json:"engine_versions,omitempty"`
+	Description     string    `json:"description,omitempty"`
+	Thumbnail       string    `json:"thumbnail,omitempty"`
+	EnrichedAt      time.Time `json:"enriched_at"`
+	// Failed fetches of the listing or article page, and when to try again
+	EnrichFailures int       `json:"enrich_failures,omitempty"`
+	EnrichRetryAt  time.Time `json:"enrich_retry_at"`
+
+	// News article details, filled in by enrichArticles. TitleGuessed marks
+	// titles made up from the URL until the article's own title is known.
+	// Published may first be estimated from "3 days ago" on the dispatch
+	// page; PublishedPrecision says how close it is.
+	Author             string    `json:"author,omitempty"`
+	Published          time.Time `json:"published"`
+	PublishedPrecision string    `json:"published_precision,omitempty"`
+	TitleGuessed       bool      `json:"title_guessed,omitempty"`
+
+	// Source that lists the asset, the last check it was listed in, and
+	// what happened to it since it was first seen
+	Source   string       `json:"source,omitempty"`
+	LastSeen time.Time    `json:"last_seen"`
+	History  []AssetEvent `json:"history,omitempty"`
 }
 
 type AppData struct {
-	SeenAssets map[string]Asset `json:"seen_assets"`
-	LastCheck  time.Time        `json:"last_check"`
+	Version    int                     `json:"version"`
+	SeenAssets map[string]Asset        `json:"seen_assets"`
+	LastCheck  time.Time               `json:"last_check"`
+	Sources    map[string]SourceStatus `json:"sources,omitempty"`
+	Batches    map[string]Batch        `json:"batches,omitempty"`
+
+	YieldHistory map[string][]YieldSample `json:"yield_history,omitempty"`
 }
 
 var (
-	appData           AppData
-	dataFile          string
-	dataDir           string
-	httpClient        *http.Client
-	fyneApp           fyne.App
-	mainWindow        fyne.Window
-	freeList          *widget.List
-	latestList        *widget.List
-	freeAssets        []Asset
-	latestAssets      []Asset
-	filteredFree      []Asset
-	filteredLatest    []Asset
-	statusLabel       *widget.Label
-	tabs              *container.AppTabs
-	searchEntry       *widget.Entry
-	currentSearchTerm string
+	dataFile       string
+	dataDir        string
+	httpClient     *http.Client
+	pageCache      *httpCache
+	fyneApp        fyne.App
+	trayMenu       *fyne.Menu
+	trayStatusItem *fyne.MenuItem
+	mainWindow     fyne.Window
+	assetLists     [tabCount]*widget.List
+	statusLabel    *widget.Label
+	tabs           *container.AppTabs
+	searchEntry    *widget.Entry
 )
 
 // Custom dark theme with Unreal orange accent
@@ -96,8 +138,12 @@ func (t *unrealTheme) Color(name fyne.ThemeColorName, variant fyne.ThemeVariant)
 	}
 }
 
-func (t *unrealTheme) Font(style fyne.TextStyle) fyne.Resource   { return theme.DefaultTheme().Font(style) }
-func (t *unrealTheme) Icon(name fyne.ThemeIconName) fyne.Resource { return theme.DefaultTheme().Icon(name) }
+func (t *unrealTheme) Font(style fyne.TextStyle) fyne.Resource {
+	return theme.DefaultTheme().Font(style)
+}
+func (t *unrealTheme) Icon(name fyne.ThemeIconName) fyne.Resource {
+	return theme.DefaultTheme().Icon(name)
+}
 func (t *unrealTheme) Size(name fyne.ThemeSizeName) float32 {
 	if name == theme.SizeNameText {
 		return 14
@@ -106,18 +152,59 @@ func (t *unrealTheme) Size(name fyne.ThemeSizeName) float32 {
 }
 
 func main() {
+	flag.Parse()
+	if flag.NArg() > 0 {
+		attachParentConsole()
+	}
+
 	appDataDir, _ := os.UserConfigDir()
 	if appDataDir == "" {
 		appDataDir = "."
 	}
 	dataDir = filepath.Join(appDataDir, "UnrealFreeAssets")
+	if *dataDirFlag != "" {
+		dataDir = *dataDirFlag
+	}
 	os.MkdirAll(dataDir, 0755)
 	dataFile = filepath.Join(dataDir, dataFileName)
 
-	httpClient = &http.Client{Timeout: 30 * time.Second}
+	if err := loadConfig(); err != nil {
+		log.Printf("Config error: %v", err)
+	}
+	if err := loadRules(); err != nil {
+		log.Printf("Rules error, using built-in rules: %v", err)
+	}
+
+	var err error
+	if config.ReplayDir != "" && *dataDirFlag == "" {
+		// A replayed check mustn't change the real history
+		if dataDir, err = os.MkdirTemp("", "unreal-free-assets-replay-"); err != nil {
+			log.Fatalf("Replay data directory error: %v", err)
+		}
+		dataFile = filepath.Join(dataDir, dataFileName)
+		log.Printf("Replaying with the empty data directory %s (use -data-dir to pick one)", dataDir)
+	}
+	if httpClient, err = newHTTPClient(); err != nil {
+		log.Fatalf("HTTP setup error: %v", err)
+	}
+	registerDefaultSources()
+	applySourceConfig()
+	if store, err = openStore(); err != nil {
+		log.Fatalf("Data store error: %v", err)
+	}
 	loadData()
+
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(flag.Args()))
+	}
+
 	initIcon()
 
+	onListsChanged = redrawLists
+	onNewAssets = notifyNewAssets
+	onSourceBroken = notifyBrokenSource
+	onStoreError = notifyStoreError
+
 	fyneApp = app.New()
 	fyneApp.Settings().SetTheme(&unrealTheme{})
 
@@ -147,18 +234,26 @@ func main() {
 }
 
 func setupSystemTray(desk desktop.App) {
+	trayStatusItem = fyne.NewMenuItem("Sources OK", nil)
+	trayStatusItem.Disabled = true
+
 	menu := fyne.NewMenu("Unreal Assets Monitor",
+		trayStatusItem,
+		fyne.NewMenuItemSeparator(),
 		fyne.NewMenuItem("View Assets", func() {
-			refreshAssetLists()
+			listsChanged()
 			mainWindow.Show()
 			mainWindow.RequestFocus()
 		}),
 		fyne.NewMenuItem("Check Now", func() {
 			go checkForAssets()
 		}),
+		fyne.NewMenuItem("Import Past Batches", func() {
+			go backfillAssets()
+		}),
 		fyne.NewMenuItemSeparator(),
 		fyne.NewMenuItem("Open FAB Marketplace", func() {
-			openBrowser("https://www.fab.com/search?price=free")
+			openBrowser(config.FabURL)
 		}),
 		fyne.NewMenuItem("Buy Me a Coffee", func() {
 			openBrowser("https://buymeacoffee.com/qvark")
@@ -166,10 +261,13 @@ func setupSystemTray(desk desktop.App) {
 		fyne.NewMenuItemSeparator(),
 		fyne.NewMenuItem("Quit", func() {
 			saveData()
+			store.Close()
 			fyneApp.Quit()
 		}),
 	)
 
+	trayMenu = menu
+	updateTrayStatus()
 	desk.SetSystemTrayMenu(menu)
 	if iconData != nil && len(iconData) > 0 {
 		desk.SetSystemTrayIcon(fyne.NewStaticResource("icon.png", iconData))
@@ -189,15 +287,15 @@ func buildMainUI() {
 	searchEntry = widget.NewEntry()
 	searchEntry.SetPlaceHolder("Search assets...")
 	searchEntry.OnChanged = func(s string) {
-		currentSearchTerm = strings.ToLower(strings.TrimSpace(s))
-		applySearchFilter()
+		view.setSearch(s)
+		redrawLists()
 	}
 
 	// Clear search button
 	clearSearchBtn := widget.NewButton("Clear", func() {
 		searchEntry.SetText("")
-		currentSearchTerm = ""
-		applySearchFilter()
+		view.setSearch("")
+		redrawLists()
 	})
 
 	searchBox := container.NewBorder(nil, nil, nil, clearSearchBtn, searchEntry)
@@ -209,31 +307,47 @@ func buildMainUI() {
 		searchBox,
 	)
 
-	// Create lists - use filtered lists for display
-	freeAssets, latestAssets = getSortedAssets()
-	filteredFree = freeAssets
-	filteredLatest = latestAssets
+	// Create lists
+	view.refresh()
+	_, shown := view.counts()
+	for tab := range assetLists {
+		assetLists[tab] = createAssetList(tab)
+	}
 
 	// FREE tab
-	freeList = createAssetList(&filteredFree)
 	freeTab := container.NewBorder(
-		createTabHeader("🎁 FREE Assets", "Claim these before they expire!", len(filteredFree)),
+		createTabHeader("🎁 FREE Assets", "Claim these before they expire!", shown[tabFree]),
+		nil, nil, nil,
+		assetLists[tabFree],
+	)
+
+	// EPIC GAMES tab
+	gamesTab := container.NewBorder(
+		createTabHeader("🎮 Epic Free Games", "This week's free games on the Epic Games Store", shown[tabGames]),
 		nil, nil, nil,
-		freeList,
+		assetLists[tabGames],
+	)
+
+	// ALWAYS FREE tab
+	permanentTab := container.NewBorder(
+		createTabHeader("🆓 Always Free", "New listings that are free on Fab for good", shown[tabPermanent]),
+		nil, nil, nil,
+		assetLists[tabPermanent],
 	)
 
 	// LATEST tab
-	latestList = createAssetList(&filteredLatest)
 	latestTab := container.NewBorder(
-		createTabHeader("🆕 Latest News", "Unreal & FAB marketplace news", len(filteredLatest)),
+		createTabHeader("🆕 Latest News", "Unreal & FAB marketplace news", shown[tabLatest]),
 		nil, nil, nil,
-		latestList,
+		assetLists[tabLatest],
 	)
 
 	// Tabs
 	tabs = container.NewAppTabs(
-		container.NewTabItem(fmt.Sprintf("Free (%d)", len(filteredFree)), freeTab),
-		container.NewTabItem(fmt.Sprintf("Latest (%d)", len(filteredLatest)), latestTab),
+		container.NewTabItem(tabTitle(tabFree, shown), freeTab),
+		container.NewTabItem(tabTitle(tabGames, shown), gamesTab),
+		container.NewTabItem(tabTitle(tabPermanent, shown), permanentTab),
+		container.NewTabItem(tabTitle(tabLatest, shown), latestTab),
 	)
 	tabs.SetTabLocation(container.TabLocationTop)
 
@@ -243,13 +357,12 @@ func buildMainUI() {
 	})
 
 	fabBtn := widget.NewButton("🌐 Open FAB", func() {
-		openBrowser("https://www.fab.com/search?price=free")
+		openBrowser(config.FabURL)
 	})
 	fabBtn.Importance = widget.HighImportance
 
 	clearBtn := widget.NewButton("🗑 Clear All", func() {
 		clearHistory()
-		refreshAssetLists()
 	})
 
 	coffeeBtn := widget.NewButton("☕ Buy Me a Coffee", func() {
@@ -265,44 +378,27 @@ func buildMainUI() {
 	updateStatusLabel()
 }
 
-func applySearchFilter() {
-	if currentSearchTerm == "" {
-		// No filter - show all
-		filteredFree = freeAssets
-		filteredLatest = latestAssets
-	} else {
-		// Filter by search term
-		filteredFree = nil
-		for _, a := range freeAssets {
-			if strings.Contains(strings.ToLower(a.Title), currentSearchTerm) ||
-				strings.Contains(strings.ToLower(a.URL), currentSearchTerm) {
-				filteredFree = append(filteredFree, a)
-			}
-		}
-
-		filteredLatest = nil
-		for _, a := range latestAssets {
-			if strings.Contains(strings.ToLower(a.Title), currentSearchTerm) ||
-				strings.Contains(strings.ToLower(a.URL), currentSearchTerm) {
-				filteredLatest = append(filteredLatest, a)
-			}
+// redrawLists shows the list view's current rows and counts
+func redrawLists() {
+	for _, list := range assetLists {
+		if list != nil {
+			list.Refresh()
 		}
 	}
-
-	// Refresh lists
-	if freeList != nil {
-		freeList.Refresh()
-	}
-	if latestList != nil {
-		latestList.Refresh()
-	}
-
-	// Update tab counts
 	if tabs != nil {
-		tabs.Items[0].Text = fmt.Sprintf("Free (%d)", len(filteredFree))
-		tabs.Items[1].Text = fmt.Sprintf("Latest (%d)", len(filteredLatest))
+		_, shown := view.counts()
+		for tab, item := range tabs.Items {
+			item.Text = tabTitle(tab, shown)
+		}
 		tabs.Refresh()
 	}
+	updateStatusLabel()
+}
+
+// tabTitle names a tab with the number of assets it shows
+func tabTitle(tab int, shown [tabCount]int) string {
+	names := [tabCount]string{"Free", "Epic Games", "Always Free", "Latest"}
+	return fmt.Sprintf("%s (%d)", names[tab], shown[tab])
 }
 
 func createTabHeader(title, subtitle string, count int) fyne.CanvasObject {
@@ -320,10 +416,14 @@ func createTabHeader(title, subtitle string, count int) fyne.CanvasObject {
 	)
 }
 
-func createAssetList(assets *[]Asset) *widget.List {
+func createAssetList(tab int) *widget.List {
 	list := widget.NewList(
-		func() int { return len(*assets) },
+		func() int { return view.length(tab) },
 		func() fyne.CanvasObject {
+			headingLabel := widget.NewLabel("Batch Heading")
+			headingLabel.TextStyle = fyne.TextStyle{Bold: true}
+			headingLabel.Alignment = fyne.TextAlignCenter
+
 			titleLabel := widget.NewLabel("Asset Title Here")
 			titleLabel.TextStyle = fyne.TextStyle{Bold: true}
 			titleLabel.Wrapping = fyne.TextTruncate
@@ -335,19 +435,33 @@ func createAssetList(assets *[]Asset) *widget.List {
 			openBtn.Importance = widget.HighImportance
 
 			left := container.NewVBox(titleLabel, infoLabel)
-			return container.NewBorder(nil, nil, nil, openBtn, left)
+			assetRow := container.NewBorder(nil, nil, nil, openBtn, left)
+			return container.NewStack(headingLabel, assetRow)
 		},
 		func(id widget.ListItemID, obj fyne.CanvasObject) {
-			if id >= len(*assets) {
+			row, ok := view.row(tab, id)
+			if !ok {
 				return
 			}
-			asset := (*assets)[id]
+			asset := row.Asset
 
 			c := obj.(*fyne.Container)
-			left := c.Objects[0].(*fyne.Container)
+			headingLabel := c.Objects[0].(*widget.Label)
+			assetRow := c.Objects[1].(*fyne.Container)
+			left := assetRow.Objects[0].(*fyne.Container)
 			titleLabel := left.Objects[0].(*widget.Label)
 			infoLabel := left.Objects[1].(*widget.Label)
-			openBtn := c.Objects[1].(*widget.Button)
+			openBtn := assetRow.Objects[1].(*widget.Button)
+
+			// Section headings (e.g. "Current batch") span the row
+			if row.Heading != "" {
+				headingLabel.SetText(row.Heading)
+				headingLabel.Show()
+				assetRow.Hide()
+				return
+			}
+			headingLabel.Hide()
+			assetRow.Show()
 
 			displayTitle := asset.Title
 			if len(displayTitle) > 60 {
@@ -357,11 +471,33 @@ func createAssetList(assets *[]Asset) *widget.List {
 
 			// Show category-specific info
 			if asset.Category == CategoryFree {
-				if asset.ExpiresAt != "" {
-					infoLabel.SetText("⏰ " + asset.ExpiresAt)
-				} else {
-					infoLabel.SetText("🎁 FREE - Claim now!")
+				info := "🎁 FREE - Claim now!"
+				if isExpired(asset, time.Now()) {
+					info = "⌛ " + expiryLabel(asset, time.Now())
+				} else if label := expiryLabel(asset, time.Now()); label != "" {
+					info = "⏰ " + label
+				}
+				if details := listingSummary(asset); details != "" {
+					info += " • " + details
+				}
+				infoLabel.SetText(info)
+			} else if asset.Category == CategoryGames {
+				info := "🎮 " + gameOfferLabel(asset, time.Now())
+				if details := listingSummary(asset); details != "" {
+					info += " • " + details
 				}
+				infoLabel.SetText(info)
+			} else if asset.Category == CategoryPermanent {
+				info := "🆓 Free for good"
+				if !asset.Published.IsZero() {
+					info += " • listed " + asset.Published.Local().Format("Jan 2")
+				}
+				if details := listingSummary(asset); details != "" {
+					info += " • " + details
+				}
+				infoLabel.SetText(info)
+			} else if details := articleSummary(asset); details != "" {
+				infoLabel.SetText("📰 " + details)
 			} else {
 				infoLabel.SetText("💰 " + asset.Price + " • Found: " + asset.FirstSeen.Format("Jan 2"))
 			}
@@ -372,8 +508,8 @@ func createAssetList(assets *[]Asset) *widget.List {
 	)
 
 	list.OnSelected = func(id widget.ListItemID) {
-		if id < len(*assets) {
-			openBrowser((*assets)[id].URL)
+		if row, ok := view.row(tab, id); ok && row.Heading == "" {
+			showAssetDetails(row.Asset)
 		}
 		list.Unselect(id)
 	}
@@ -381,269 +517,187 @@ func createAssetList(assets *[]Asset) *widget.List {
 	return list
 }
 
-func refreshAssetLists() {
-	freeAssets, latestAssets = getSortedAssets()
-	// Re-apply current search filter
-	applySearchFilter()
-	updateStatusLabel()
-}
-
-func updateStatusLabel() {
-	if statusLabel == nil {
-		return
-	}
-	checkTime := "Never"
-	if !appData.LastCheck.IsZero() {
-		checkTime = appData.LastCheck.Format("Jan 2, 15:04")
-	}
-	total := len(freeAssets) + len(latestAssets)
-	statusLabel.SetText(fmt.Sprintf("%d free • %d latest • Last check: %s", len(freeAssets), len(latestAssets), checkTime))
-	_ = total
-}
-
-func getSortedAssets() ([]Asset, []Asset) {
-	var free, latest []Asset
-	for _, asset := range appData.SeenAssets {
-		if asset.Category == CategoryFree {
-			free = append(free, asset)
-		} else {
-			latest = append(latest, asset)
+// showAssetDetails opens a window with everything known about an asset and
+// its history, newest first
+func showAssetDetails(asset Asset) {
+	// The list may be a check behind
+	state.read(func(d *AppData) {
+		if stored, ok := d.SeenAssets[asset.URL]; ok {
+			asset = stored
 		}
-	}
-	sort.Slice(free, func(i, j int) bool {
-		return free[i].FirstSeen.After(free[j].FirstSeen)
 	})
-	sort.Slice(latest, func(i, j int) bool {
-		return latest[i].FirstSeen.After(latest[j].FirstSeen)
-	})
-	return free, latest
-}
+	now := time.Now()
 
-func backgroundChecker() {
-	ticker := time.NewTicker(checkInterval)
-	defer ticker.Stop()
-	for range ticker.C {
-		checkForAssets()
-	}
-}
+	titleLabel := widget.NewLabel(asset.Title)
+	titleLabel.TextStyle = fyne.TextStyle{Bold: true}
+	titleLabel.Wrapping = fyne.TextWrapWord
 
-func checkForAssets() {
-	log.Println("Checking for assets...")
-	newFreeAssets := []Asset{}
-	newLatestAssets := []Asset{}
-
-	// Scrape Unreal Source for FREE and Latest assets
-	free, latest, err := scrapeUnrealSource()
-	if err != nil {
-		log.Printf("Unreal Source error: %v", err)
-	} else {
-		for _, a := range free {
-			if _, seen := appData.SeenAssets[a.URL]; !seen {
-				a.FirstSeen = time.Now()
-				appData.SeenAssets[a.URL] = a
-				newFreeAssets = append(newFreeAssets, a)
-			}
+	var lines []string
+	switch asset.Category {
+	case CategoryFree:
+		if label := expiryLabel(asset, now); label != "" {
+			lines = append(lines, "⏰ "+label)
 		}
-		for _, a := range latest {
-			if _, seen := appData.SeenAssets[a.URL]; !seen {
-				a.FirstSeen = time.Now()
-				appData.SeenAssets[a.URL] = a
-				newLatestAssets = append(newLatestAssets, a)
-			}
+	case CategoryGames:
+		lines = append(lines, "🎮 "+gameOfferLabel(asset, now))
+	case CategoryPermanent:
+		lines = append(lines, "🆓 Free for good")
+	case CategoryLatest:
+		if details := articleSummary(asset); details != "" {
+			lines = append(lines, "📰 "+details)
 		}
 	}
-
-	appData.LastCheck = time.Now()
-	saveData()
-	refreshAssetLists()
-
-	if len(newFreeAssets) > 0 {
-		notifyNewAssets(newFreeAssets, true)
+	if details := listingSummary(asset); details != "" {
+		lines = append(lines, details)
 	}
-	if len(newLatestAssets) > 0 {
-		notifyNewAssets(newLatestAssets, false)
+	if asset.DispatchURL != "" {
+		lines = append(lines, "Announced in "+asset.DispatchURL)
 	}
-
-	log.Printf("Check complete. Found %d new free, %d new latest.", len(newFreeAssets), len(newLatestAssets))
-}
-
-func scrapeUnrealSource() ([]Asset, []Asset, error) {
-	var freeAssets []Asset
-	var latestAssets []Asset
-	seenURLs := make(map[string]bool)
-
-	// First, get the dispatch page to find links to free asset announcements
-	req, _ := http.NewRequest("GET", unrealSourceURL, nil)
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return nil, nil, err
+	seen := "First seen " + asset.FirstSeen.Local().Format("Jan 2 2006, 15:04")
+	if !asset.LastSeen.IsZero() {
+		seen += " • last listed " + asset.LastSeen.Local().Format("Jan 2 2006, 15:04")
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, nil, fmt.Errorf("status %d", resp.StatusCode)
+	if asset.Source != "" {
+		seen += " by " + asset.Source
 	}
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return nil, nil, err
+	lines = append(lines, seen)
+	infoLabel := widget.NewLabel(strings.Join(lines, "\n"))
+	infoLabel.Wrapping = fyne.TextWrapWord
+
+	historyBox := container.NewVBox()
+	for i := len(asset.History) - 1; i >= 0; i-- {
+		e := asset.History[i]
+		label := widget.NewLabel(e.Time.Local().Format("Jan 2 2006, 15:04") + " — " + eventLabel(e))
+		label.Wrapping = fyne.TextWrapWord
+		historyBox.Add(label)
 	}
-
-	// Find links to "free fab assets" detail pages
-	// These are dispatch articles with URLs like /d/free-fab-assets-*
-	freeDispatchLinks := []string{}
-	doc.Find("a[href*='/d/free-fab-assets']").Each(func(i int, link *goquery.Selection) {
-		href, exists := link.Attr("href")
-		if exists && !strings.HasPrefix(href, "http") {
-			href = "https://unrealsource.com" + href
-		}
-		if exists && href != "" {
-			// Dedupe
-			for _, existing := range freeDispatchLinks {
-				if existing == href {
-					return
-				}
-			}
-			freeDispatchLinks = append(freeDispatchLinks, href)
-		}
-	})
-
-	// Fetch each free assets detail page (usually just 1 - the current batch)
-	// Only process the first one (most recent)
-	if len(freeDispatchLinks) > 0 {
-		free := scrapeFreeAssetsPage(freeDispatchLinks[0], seenURLs)
-		freeAssets = append(freeAssets, free...)
+	if len(asset.History) == 0 {
+		historyBox.Add(widget.NewLabel("No history recorded yet"))
 	}
-
-	// Collect other dispatch links as "latest" news
-	doc.Find("a[href*='/d/']").Each(func(i int, link *goquery.Selection) {
-		href, exists := link.Attr("href")
-		if !exists {
-			return
-		}
-		// Skip free-fab-assets pages (already processed)
-		if strings.Contains(href, "free-fab-assets") {
-			return
-		}
-		if !strings.HasPrefix(href, "http") {
-			href = "https://unrealsource.com" + href
-		}
-		if seenURLs[href] {
-			return
-		}
-
-		// Try to get title from the link text, or extract from URL if it's a timestamp
-		title := strings.TrimSpace(link.Text())
-
-		// If title looks like a relative time, extract from URL instead
-		if title == "" || len(title) < 5 || strings.Contains(title, "ago") ||
-		   strings.Contains(title, "month") || strings.Contains(title, "year") ||
-		   strings.Contains(title, "week") || strings.Contains(title, "day") {
-			// Extract title from URL: /d/some-article-title/ -> Some Article Title
-			parts := strings.Split(href, "/d/")
-			if len(parts) > 1 {
-				slug := strings.TrimSuffix(parts[1], "/")
-				slug = strings.ReplaceAll(slug, "-", " ")
-				// Capitalize first letter of each word
-				words := strings.Fields(slug)
-				for i, w := range words {
-					if len(w) > 0 {
-						words[i] = strings.ToUpper(string(w[0])) + w[1:]
-					}
-				}
-				title = strings.Join(words, " ")
-			}
-		}
-
-		if title == "" || len(title) < 5 {
-			return
-		}
-		if len(title) > 80 {
-			title = title[:77] + "..."
-		}
-
-		seenURLs[href] = true
-		latestAssets = append(latestAssets, Asset{
-			Title:    title,
-			URL:      href,
-			Price:    "News",
-			Category: CategoryLatest,
-		})
-	})
-
-	log.Printf("Scraped: %d free assets, %d latest assets", len(freeAssets), len(latestAssets))
-	return freeAssets, latestAssets, nil
+	historyHeading := widget.NewLabel("History")
+	historyHeading.TextStyle = fyne.TextStyle{Bold: true}
+
+	w := fyneApp.NewWindow(asset.Title)
+	url := asset.URL
+	openBtn := widget.NewButton("Open", func() { openBrowser(url) })
+	openBtn.Importance = widget.HighImportance
+	closeBtn := widget.NewButton("Close", func() { w.Close() })
+
+	top := container.NewVBox(titleLabel, infoLabel, widget.NewSeparator(), historyHeading)
+	buttons := container.NewHBox(layout.NewSpacer(), closeBtn, openBtn)
+	w.SetContent(container.NewBorder(top, buttons, nil, nil, container.NewVScroll(historyBox)))
+	w.Resize(fyne.NewSize(560, 480))
+	w.Show()
 }
 
-func scrapeFreeAssetsPage(url string, seenURLs map[string]bool) []Asset {
-	var assets []Asset
-
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		log.Printf("Error fetching free assets page: %v", err)
-		return assets
+// listingSummary describes a free asset's seller, category, price and engine support
+func listingSummary(a Asset) string {
+	var parts []string
+	if a.Seller != "" {
+		parts = append(parts, "by "+a.Seller)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		log.Printf("Free assets page returned status %d", resp.StatusCode)
-		return assets
+	if a.ListingCategory != "" {
+		parts = append(parts, a.ListingCategory)
 	}
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		log.Printf("Error parsing free assets page: %v", err)
-		return assets
+	if a.OriginalPrice != "" {
+		parts = append(parts, "was "+a.OriginalPrice)
 	}
-
-	// Extract expiration date from page text
-	expiresPattern := regexp.MustCompile(`(?i)(?:until|before).*?(\w+\s+\d+,?\s*202\d)`)
-	expiresAt := ""
-	pageText := doc.Text()
-	if matches := expiresPattern.FindStringSubmatch(pageText); len(matches) > 1 {
-		expiresAt = "Free until " + matches[1]
+	if len(a.EngineVersions) > 0 {
+		parts = append(parts, "UE "+strings.Join(a.EngineVersions, ", "))
 	}
+	return strings.Join(parts, " • ")
+}
 
-	// Find all fab.com/listings links on this page - these are the free assets
-	doc.Find("a[href*='fab.com/listings']").Each(func(i int, link *goquery.Selection) {
-		href, exists := link.Attr("href")
-		if !exists || seenURLs[href] {
-			return
+// articleSummary describes a news item's author, date and description
+func articleSummary(a Asset) string {
+	var parts []string
+	if a.Author != "" {
+		parts = append(parts, "by "+a.Author)
+	}
+	if !a.Published.IsZero() {
+		parts = append(parts, publishedLabel(a))
+	}
+	if a.Description != "" {
+		desc := a.Description
+		if len(desc) > 80 {
+			desc = desc[:77] + "..."
 		}
+		parts = append(parts, desc)
+	}
+	return strings.Join(parts, " • ")
+}
 
-		title := strings.TrimSpace(link.Text())
-		if title == "" || len(title) < 3 {
-			return
+func updateStatusLabel() {
+	if statusLabel == nil {
+		return
+	}
+	checkTime := "Never"
+	var broken, failing, warned []string
+	state.read(func(d *AppData) {
+		if !d.LastCheck.IsZero() {
+			checkTime = d.LastCheck.Format("Jan 2, 15:04")
 		}
-
-		seenURLs[href] = true
-		assets = append(assets, Asset{
-			Title:     title,
-			URL:       href,
-			Price:     "FREE",
-			Category:  CategoryFree,
-			ExpiresAt: expiresAt,
-		})
+		broken, failing, warned = brokenSources(d), failingSources(d), robotsWarnedSources(d)
 	})
+	total, _ := view.counts()
+	status := fmt.Sprintf("%d free • %d games • %d always free • %d latest • Last check: %s",
+		total[tabFree], total[tabGames], total[tabPermanent], total[tabLatest], checkTime)
+	if err := state.storeError(); err != nil {
+		status += " • 💾 " + err.Error()
+	} else if len(broken) > 0 {
+		status += " • 🛑 Source looks broken: " + strings.Join(broken, ", ")
+	} else if len(failing) > 0 {
+		status += " • ⚠ " + strings.Join(failing, ", ")
+	} else if len(warned) > 0 {
+		status += " • ℹ robots.txt skipped pages: " + strings.Join(warned, ", ")
+	}
+	statusLabel.SetText(status)
+	updateTrayStatus()
+}
 
-	log.Printf("Found %d free assets on detail page", len(assets))
-	return assets
+// updateTrayStatus shows source health at the top of the tray menu
+func updateTrayStatus() {
+	if trayStatusItem == nil {
+		return
+	}
+	var broken, failing, warned []string
+	state.read(func(d *AppData) {
+		broken, failing, warned = brokenSources(d), failingSources(d), robotsWarnedSources(d)
+	})
+	label := "Sources OK"
+	if state.storeError() != nil {
+		label = "💾 Can't save seen assets"
+	} else if len(broken) > 0 {
+		label = "🛑 Broken: " + strings.Join(broken, ", ")
+	} else if len(failing) > 0 {
+		label = "⚠ Errors: " + strings.Join(failing, ", ")
+	} else if len(warned) > 0 {
+		label = "ℹ Blocked by robots.txt: " + strings.Join(warned, ", ")
+	}
+	trayStatusItem.Label = label
+	trayMenu.Refresh()
 }
 
-func notifyNewAssets(assets []Asset, isFree bool) {
+func notifyNewAssets(assets []Asset, category string) {
 	title := "New Assets Found!"
-	if isFree {
+	switch category {
+	case CategoryFree:
 		title = "🎁 New FREE Assets!"
+	case CategoryGames:
+		title = "🎮 New free games on Epic!"
+	case CategoryPermanent:
+		title = "🆓 New always-free assets on Fab"
 	}
 
 	msg := fmt.Sprintf("%d new assets", len(assets))
 	if len(assets) == 1 {
 		msg = assets[0].Title
+		if category == CategoryGames {
+			msg += "\n" + gameOfferLabel(assets[0], time.Now())
+		}
+		if details := listingSummary(assets[0]); details != "" {
+			msg += "\n" + details
+		}
 	} else if len(assets) <= 3 {
 		var titles []string
 		for _, a := range assets {
@@ -660,23 +714,22 @@ func notifyNewAssets(assets []Asset, isFree bool) {
 	notification.Push()
 }
 
-func clearHistory() {
-	appData.SeenAssets = make(map[string]Asset)
-	saveData()
-	log.Println("History cleared")
-}
-
-func loadData() {
-	appData.SeenAssets = make(map[string]Asset)
-	data, err := os.ReadFile(dataFile)
-	if err == nil {
-		json.Unmarshal(data, &appData)
+func notifyBrokenSource(name, reason string) {
+	notification := toast.Notification{
+		AppID:   "Unreal Assets Monitor",
+		Title:   "🛑 Source looks broken",
+		Message: fmt.Sprintf("%s: %s. The site layout may have changed; new assets could be missed.", name, reason),
 	}
+	notification.Push()
 }
 
-func saveData() {
-	data, _ := json.MarshalIndent(appData, "", "  ")
-	os.WriteFile(dataFile, data, 0644)
+func notifyStoreError(err error) {
+	notification := toast.Notification{
+		AppID:   "Unreal Assets Monitor",
+		Title:   "💾 Data not saved",
+		Message: err.Error(),
+	}
+	notification.Push()
 }
 
 func openBrowser(url string) {
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// dataVersion is the seen_assets.json layout written by saveData. Files
+// without a version predate versioning and count as version 1.
+const dataVersion = 5
+
+// dataMigrations upgrade the decoded data file from the keyed version to the next
+var dataMigrations = map[int]func(raw map[string]interface{}) error{
+	1: migrateExpiryToTime,
+	2: migrateBatchesFromAssets,
+	3: migrateCanonicalURLs,
+	// Article URLs are canonical too from version 5 on
+	4: migrateCanonicalURLs,
+}
+
+// migrateData upgrades an older data file to dataVersion
+func migrateData(data []byte) ([]byte, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	version := 1
+	if v, ok := raw["version"].(float64); ok && v >= 1 {
+		version = int(v)
+	}
+	if version == dataVersion {
+		return data, nil
+	}
+	if version > dataVersion {
+		return nil, fmt.Errorf("data file version %d is newer than this app (%d)", version, dataVersion)
+	}
+
+	for ; version < dataVersion; version++ {
+		if err := dataMigrations[version](raw); err != nil {
+			return nil, fmt.Errorf("migrating data from version %d: %w", version, err)
+		}
+	}
+	raw["version"] = dataVersion
+	return json.Marshal(raw)
+}
+
+// migrateExpiryToTime moves the free-text expires_at ("Free until January
+// 14, 2025") to expires_text and stores the parsed deadline in expires_at
+func migrateExpiryToTime(raw map[string]interface{}) error {
+	assets, _ := raw["seen_assets"].(map[string]interface{})
+	for _, v := range assets {
+		asset, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		text, _ := asset["expires_at"].(string)
+		delete(asset, "expires_at")
+		if text == "" {
+			continue
+		}
+		asset["expires_text"] = text
+
+		var ref time.Time
+		if s, ok := asset["first_seen"].(string); ok {
+			ref, _ = time.Parse(time.RFC3339, s)
+		}
+		if at, _, ok := parseExpiry(text, ref); ok {
+			asset["expires_at"] = at.Format(time.RFC3339)
+		}
+	}
+	return nil
+}
+
+// migrateBatchesFromAssets creates a batch for every dispatch article that
+// stored assets were imported from
+func migrateBatchesFromAssets(raw map[string]interface{}) error {
+	assets, _ := raw["seen_assets"].(map[string]interface{})
+	batches := make(map[string]interface{})
+	for key, v := range assets {
+		asset, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		article, _ := asset["dispatch_url"].(string)
+		if article == "" {
+			continue
+		}
+
+		batch, ok := batches[article].(map[string]interface{})
+		if !ok {
+			batch = map[string]interface{}{
+				"article_url": article,
+				"announced":   asset["announced"],
+				"valid_from":  asset["announced"],
+				"valid_until": asset["expires_at"],
+				"asset_urls":  []interface{}{},
+			}
+			batches[article] = batch
+		}
+		url, _ := asset["url"].(string)
+		if url == "" {
+			url = key
+		}
+		batch["asset_urls"] = append(batch["asset_urls"].([]interface{}), url)
+	}
+	if len(batches) > 0 {
+		raw["batches"] = batches
+	}
+	return nil
+}
+
+// migrateCanonicalURLs rekeys assets and batches by canonical URL and merges
+// the duplicates that raw URLs produced. A merged asset keeps the earliest
+// first_seen, the latest last_seen and the events of every duplicate, and
+// fills its empty fields from the duplicates.
+func migrateCanonicalURLs(raw map[string]interface{}) error {
+	if assets, ok := raw["seen_assets"].(map[string]interface{}); ok {
+		merged := make(map[string]interface{})
+		for key, v := range assets {
+			asset, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			url, _ := asset["url"].(string)
+			if url == "" {
+				url = key
+			}
+			url = canonicalURL(url)
+			asset["url"] = url
+			if article, ok := asset["dispatch_url"].(string); ok && article != "" {
+				asset["dispatch_url"] = canonicalURL(article)
+			}
+
+			existing, ok := merged[url].(map[string]interface{})
+			if !ok {
+				merged[url] = asset
+				continue
+			}
+			if earlierTime(asset["first_seen"], existing["first_seen"]) {
+				existing["first_seen"] = asset["first_seen"]
+			}
+			// The source that listed it last goes with the last sighting
+			if earlierTime(existing["last_seen"], asset["last_seen"]) {
+				existing["last_seen"], existing["source"] = asset["last_seen"], asset["source"]
+			}
+			if history := mergeHistories(existing["history"], asset["history"]); len(history) > 0 {
+				existing["history"] = history
+			}
+			fillEmptyFields(existing, asset)
+		}
+		raw["seen_assets"] = merged
+	}
+
+	if batches, ok := raw["batches"].(map[string]interface{}); ok {
+		merged := make(map[string]interface{})
+		for key, v := range batches {
+			batch, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			article, _ := batch["article_url"].(string)
+			if article == "" {
+				article = key
+			}
+			article = canonicalURL(article)
+			batch["article_url"] = article
+
+			existing, ok := merged[article].(map[string]interface{})
+			if !ok {
+				existing = batch
+				merged[article] = batch
+			} else {
+				fillEmptyFields(existing, batch)
+			}
+
+			var urls []interface{}
+			seen := make(map[string]bool)
+			for _, list := range []interface{}{existing["asset_urls"], batch["asset_urls"]} {
+				items, _ := list.([]interface{})
+				for _, item := range items {
+					u, ok := item.(string)
+					if !ok {
+						continue
+					}
+					if u = canonicalURL(u); !seen[u] {
+						seen[u] = true
+						urls = append(urls, u)
+					}
+				}
+			}
+			existing["asset_urls"] = urls
+		}
+		raw["batches"] = merged
+	}
+	return nil
+}
+
+// mergeHistories combines the histories of two duplicates of an asset in
+// time order. Each duplicate starts with its own "appeared" event; only the
+// first is kept. Like addEvent, it keeps the first event and the latest
+// ones up to maxAssetHistory.
+func mergeHistories(a, b interface{}) []interface{} {
+	events, _ := a.([]interface{})
+	more, _ := b.([]interface{})
+	events = append(append([]interface{}{}, events...), more...)
+	eventTime := func(e interface{}) time.Time {
+		m, _ := e.(map[string]interface{})
+		s, _ := m["time"].(string)
+		t, _ := time.Parse(time.RFC3339, s)
+		return t
+	}
+	sort.SliceStable(events, func(i, j int) bool { return eventTime(events[i]).Before(eventTime(events[j])) })
+
+	var history []interface{}
+	appeared := false
+	for _, e := range events {
+		if m, ok := e.(map[string]interface{}); ok && m["type"] == eventAppeared {
+			if appeared {
+				continue
+			}
+			appeared = true
+		}
+		history = append(history, e)
+	}
+	if len(history) > maxAssetHistory {
+		history = append(history[:1:1], history[len(history)-maxAssetHistory+1:]...)
+	}
+	return history
+}
+
+// fillEmptyFields copies the fields of src that are missing or zero in dst
+func fillEmptyFields(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if isEmptyJSON(dst[k]) && !isEmptyJSON(v) {
+			dst[k] = v
+		}
+	}
+}
+
+func isEmptyJSON(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case string:
+		if at, err := time.Parse(time.RFC3339, t); err == nil {
+			return at.IsZero()
+		}
+		return t == ""
+	case bool:
+		return !t
+	case []interface{}:
+		return len(t) == 0
+	}
+	return false
+}
+
+// earlierTime reports whether timestamp a is before b; unset times are never earlier
+func earlierTime(a, b interface{}) bool {
+	sa, _ := a.(string)
+	sb, _ := b.(string)
+	ta, errA := time.Parse(time.RFC3339, sa)
+	tb, errB := time.Parse(time.RFC3339, sb)
+	if errA != nil || ta.IsZero() {
+		return false
+	}
+	return errB != nil || tb.IsZero() || ta.Before(tb)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMigrateMergesDuplicateHistories(t *testing.T) {
+	const url = "https://www.fab.com/listings/0b7e1a52-9c8f-4c1e-8d0a-1f2e3d4c5b6a"
+	old := []byte(`{
+  "version": 4,
+  "seen_assets": {
+    "https://www.fab.com/listings/0b7e1a52-9c8f-4c1e-8d0a-1f2e3d4c5b6a": {
+      "title": "Old Town",
+      "url": "https://www.fab.com/listings/0b7e1a52-9c8f-4c1e-8d0a-1f2e3d4c5b6a",
+      "category": "free",
+      "first_seen": "2025-01-01T10:00:00Z",
+      "source": "unrealsource",
+      "last_seen": "2025-01-03T10:00:00Z",
+      "history": [
+        {"time": "2025-01-01T10:00:00Z", "type": "appeared"},
+        {"time": "2025-01-03T10:00:00Z", "type": "expired"}
+      ]
+    },
+    "https://www.fab.com/de/listings/0b7e1a52-9c8f-4c1e-8d0a-1f2e3d4c5b6a?utm_source=x": {
+      "title": "Old Town",
+      "url": "https://www.fab.com/de/listings/0b7e1a52-9c8f-4c1e-8d0a-1f2e3d4c5b6a?utm_source=x",
+      "category": "free",
+      "first_seen": "2025-01-02T10:00:00Z",
+      "source": "fabsearch",
+      "last_seen": "2025-01-05T10:00:00Z",
+      "history": [
+        {"time": "2025-01-02T10:00:00Z", "type": "appeared"},
+        {"time": "2025-01-04T10:00:00Z", "type": "changed", "field": "price", "from": "$10", "to": "Free"}
+      ]
+    }
+  }
+}`)
+
+	migrated, err := migrateData(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d AppData
+	if err := json.Unmarshal(migrated, &d); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.SeenAssets) != 1 {
+		t.Fatalf("%d assets after migration, want the duplicates merged into 1", len(d.SeenAssets))
+	}
+	a, ok := d.SeenAssets[url]
+	if !ok {
+		t.Fatalf("merged asset not stored under %s", url)
+	}
+
+	day := func(n int) time.Time { return time.Date(2025, 1, n, 10, 0, 0, 0, time.UTC) }
+	if !a.FirstSeen.Equal(day(1)) || !a.LastSeen.Equal(day(5)) || a.Source != "fabsearch" {
+		t.Errorf("first seen %v, last seen %v by %q; want %v, %v by fabsearch", a.FirstSeen, a.LastSeen, a.Source, day(1), day(5))
+	}
+	want := []AssetEvent{
+		{Time: day(1), Type: eventAppeared},
+		{Time: day(3), Type: eventExpired},
+		{Time: day(4), Type: eventChanged, Field: "price", From: "$10", To: "Free"},
+	}
+	if len(a.History) != len(want) {
+		t.Fatalf("history %+v, want %+v", a.History, want)
+	}
+	for i, e := range want {
+		if got := a.History[i]; !got.Time.Equal(e.Time) || got.Type != e.Type || got.Field != e.Field || got.From != e.From || got.To != e.To {
+			t.Errorf("event %d: %+v, want %+v", i, got, e)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// forEachLimit calls fn(i) for i in [0, n) on at most workers goroutines and
+// waits for them to finish. Once ctx is done no new items are started; fn
+// must watch ctx itself for the ones already running. Requests stay polite
+// because every fetch still waits for its host's rate limiter.
+func forEachLimit(ctx context.Context, workers, n int, fn func(i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+type detailDeadlineKey struct{}
+
+// withDetailDeadline starts a run's deadline for detail pages (dispatch
+// articles, listing and news pages). Every detailContext derived from the
+// returned context shares it, so one check spends at most the configured
+// time on them in all.
+func withDetailDeadline(ctx context.Context) context.Context {
+	deadline := time.Now().Add(time.Duration(config.DetailTimeoutSeconds) * time.Second)
+	return context.WithValue(ctx, detailDeadlineKey{}, deadline)
+}
+
+// detailContext bounds detail-page fetches by the run's deadline, or by a
+// deadline of their own outside a run
+func detailContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Value(detailDeadlineKey{}).(time.Time)
+	if !ok {
+		deadline = time.Now().Add(time.Duration(config.DetailTimeoutSeconds) * time.Second)
+	}
+	return context.WithDeadline(ctx, deadline)
+}
+
+// skippedError is err, or for a page the pool never got to before the
+// deadline, an error saying so
+func skippedError(ctx context.Context, err error, url string) error {
+	if err != nil {
+		return err
+	}
+	return &fetchError{URL: url, Err: ctx.Err()}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDetailContextsShareTheRunDeadline(t *testing.T) {
+	run := withDetailDeadline(context.Background())
+	first, cancelFirst := detailContext(run)
+	defer cancelFirst()
+	time.Sleep(10 * time.Millisecond)
+	second, cancelSecond := detailContext(run)
+	defer cancelSecond()
+
+	d1, _ := first.Deadline()
+	d2, _ := second.Deadline()
+	if !d1.Equal(d2) {
+		t.Errorf("detail contexts of one run end at %v and %v", d1, d2)
+	}
+}
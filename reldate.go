@@ -0,0 +1,84 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How exact a published date is. Dates read from an article are exact;
+// ones worked out from "2 weeks ago" are only as good as their unit.
+const (
+	precisionExact  = "exact"
+	precisionMinute = "minute"
+	precisionHour   = "hour"
+	precisionDay    = "day"
+	precisionWeek   = "week"
+	precisionMonth  = "month"
+	precisionYear   = "year"
+)
+
+var relativeTimePattern = regexp.MustCompile(`^(?:about|around|over|almost|nearly)?\s*(an?|one|\d+)\s*(s|secs?|seconds?|m|mins?|minutes?|h|hrs?|hours?|d|days?|w|wks?|weeks?|mos?|months?|y|yrs?|years?)\s+ago$`)
+
+// parseRelativeTime turns phrases like "3 days ago", "a month ago" or
+// "yesterday" into an approximate time before ref. It returns the time,
+// its precision and whether text was such a phrase.
+func parseRelativeTime(text string, ref time.Time) (time.Time, string, bool) {
+	text = strings.ToLower(strings.Join(strings.Fields(text), " "))
+	switch text {
+	case "just now", "now", "moments ago", "a moment ago", "a few seconds ago":
+		return ref, precisionMinute, true
+	case "today":
+		return ref, precisionDay, true
+	case "yesterday":
+		return ref.AddDate(0, 0, -1), precisionDay, true
+	case "last week":
+		return ref.AddDate(0, 0, -7), precisionWeek, true
+	case "last month":
+		return ref.AddDate(0, -1, 0), precisionMonth, true
+	case "last year":
+		return ref.AddDate(-1, 0, 0), precisionYear, true
+	}
+
+	m := relativeTimePattern.FindStringSubmatch(text)
+	if m == nil {
+		return time.Time{}, "", false
+	}
+	n := 1
+	if v, err := strconv.Atoi(m[1]); err == nil {
+		n = v
+	}
+
+	// "5m ago" is minutes; months are always spelled "mo" or longer
+	switch unit := m[2]; {
+	case unit == "s" || strings.HasPrefix(unit, "sec"):
+		return ref.Add(-time.Duration(n) * time.Second), precisionMinute, true
+	case unit == "m" || strings.HasPrefix(unit, "min"):
+		return ref.Add(-time.Duration(n) * time.Minute), precisionMinute, true
+	case strings.HasPrefix(unit, "h"):
+		return ref.Add(-time.Duration(n) * time.Hour), precisionHour, true
+	case strings.HasPrefix(unit, "d"):
+		return ref.AddDate(0, 0, -n), precisionDay, true
+	case strings.HasPrefix(unit, "w"):
+		return ref.AddDate(0, 0, -7*n), precisionWeek, true
+	case strings.HasPrefix(unit, "mo"):
+		return ref.AddDate(0, -n, 0), precisionMonth, true
+	default:
+		return ref.AddDate(-n, 0, 0), precisionYear, true
+	}
+}
+
+// publishedLabel shows a published date no more exactly than it is known
+func publishedLabel(a Asset) string {
+	local := a.Published.Local()
+	switch a.PublishedPrecision {
+	case precisionWeek:
+		return "week of " + local.Format("Jan 2, 2006")
+	case precisionMonth:
+		return "around " + local.Format("Jan 2006")
+	case precisionYear:
+		return "around " + local.Format("2006")
+	}
+	return local.Format("Jan 2, 2006")
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// robots.txt handling (RFC 9309): every fetch first checks the site's
+// robots.txt for our User-Agent. Disallowed pages aren't requested, and a
+// Crawl-delay slows that host's rate limiter down to match.
+const (
+	robotsTTL         = 24 * time.Hour
+	robotsRetryTTL    = 15 * time.Minute // after robots.txt couldn't be fetched
+	robotsMaxBodySize = 500 << 10
+)
+
+// errRobotsDisallowed is wrapped by fetch errors for pages robots.txt keeps us out of
+var errRobotsDisallowed = errors.New("disallowed by robots.txt")
+
+// errRobotsUnreachable is wrapped by fetch errors for pages on a site whose
+// robots.txt couldn't be fetched. The site is probably down, so unlike
+// errRobotsDisallowed it's a failure.
+var errRobotsUnreachable = errors.New("robots.txt unreachable")
+
+// robotsPolicy is the part of a robots.txt that applies to us
+type robotsPolicy struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+type robotsRule struct {
+	allow   bool
+	length  int // pattern length; the longest match wins
+	pattern *regexp.Regexp
+}
+
+type robotsEntry struct {
+	// Held while robots.txt is fetched; a channel so waiting can be
+	// abandoned when the caller's context ends
+	lock    chan struct{}
+	policy  *robotsPolicy
+	err     error // why robots.txt couldn't be fetched
+	expires time.Time
+}
+
+var (
+	robotsMu    sync.Mutex
+	robotsCache = make(map[string]*robotsEntry)
+)
+
+// checkRobots returns an error wrapping errRobotsDisallowed if robots.txt
+// disallows u for us, or errRobotsUnreachable if it couldn't be fetched.
+// The first request to a host fetches its robots.txt.
+func checkRobots(ctx context.Context, client *http.Client, u *url.URL) error {
+	if u.Path == "/robots.txt" {
+		return nil
+	}
+	policy, err := robotsFor(ctx, client, u)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return &fetchError{URL: u.String(), Err: ctxErr}
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w: %v", u, errRobotsUnreachable, err)
+	}
+	if !policy.allowed(u.RequestURI()) {
+		return fmt.Errorf("%s: %w", u, errRobotsDisallowed)
+	}
+	return nil
+}
+
+// robotsFor returns the cached policy for u's site, fetching it when missing
+// or stale. Concurrent callers for one site wait for a single fetch, or
+// until their context ends. If robots.txt couldn't be fetched, it returns
+// the error until the next try; a fetch cut short by the caller's context
+// isn't remembered.
+func robotsFor(ctx context.Context, client *http.Client, u *url.URL) (*robotsPolicy, error) {
+	site := u.Scheme + "://" + u.Host
+	robotsMu.Lock()
+	entry, ok := robotsCache[site]
+	if !ok {
+		entry = &robotsEntry{lock: make(chan struct{}, 1)}
+		robotsCache[site] = entry
+	}
+	robotsMu.Unlock()
+
+	select {
+	case entry.lock <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-entry.lock }()
+	if entry.policy != nil && time.Now().Before(entry.expires) {
+		return entry.policy, entry.err
+	}
+
+	policy, err := fetchRobots(ctx, client, site)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	entry.policy, entry.err, entry.expires = policy, err, time.Now().Add(robotsTTL)
+	if err != nil {
+		log.Printf("robots.txt for %s: %v", site, err)
+		entry.expires = time.Now().Add(robotsRetryTTL)
+	}
+	if policy.crawlDelay > 0 {
+		hostLimiter(u.Host).slowTo(policy.crawlDelay)
+	}
+	return policy, err
+}
+
+// fetchRobots downloads and parses a site's robots.txt. Following RFC 9309,
+// a missing file (4xx) allows everything, while a server error or network
+// failure disallows everything until the next attempt.
+func fetchRobots(ctx context.Context, client *http.Client, site string) (*robotsPolicy, error) {
+	disallowAll := &robotsPolicy{rules: []robotsRule{{allow: false, length: 1, pattern: regexp.MustCompile(`^/`)}}}
+
+	req, err := http.NewRequestWithContext(withoutHTTPCache(ctx), "GET", site+"/robots.txt", nil)
+	if err != nil {
+		return disallowAll, err
+	}
+	if !rateLimitDisabled {
+		if err := hostLimiter(req.URL.Host).wait(ctx); err != nil {
+			return disallowAll, err
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return disallowAll, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return parseRobots(io.LimitReader(resp.Body, robotsMaxBodySize), robotsAgent()), nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return &robotsPolicy{}, nil
+	default:
+		return disallowAll, fmt.Errorf("status %d", resp.StatusCode)
+	}
+}
+
+// robotsAgent is the product token of our User-Agent, e.g. "UnrealFreeAssets"
+func robotsAgent() string {
+	agent := strings.Fields(config.UserAgent + " ")[0]
+	if i := strings.Index(agent, "/"); i >= 0 {
+		agent = agent[:i]
+	}
+	return strings.ToLower(agent)
+}
+
+// parseRobots reads the group for agent, or the "*" group if there is none
+func parseRobots(r io.Reader, agent string) *robotsPolicy {
+	type group struct {
+		agents []string
+		policy robotsPolicy
+	}
+	var groups []*group
+	var current *group
+	inAgents := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				current = &group{}
+				groups = append(groups, current)
+				inAgents = true
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			inAgents = false
+			if current == nil || value == "" {
+				// An empty Disallow allows everything, which is the default
+				continue
+			}
+			current.policy.rules = append(current.policy.rules, robotsRule{
+				allow:   key == "allow",
+				length:  len(value),
+				pattern: robotsPattern(value),
+			})
+		case "crawl-delay":
+			inAgents = false
+			if current == nil {
+				continue
+			}
+			if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+				current.policy.crawlDelay = time.Duration(secs * float64(time.Second))
+			}
+		}
+	}
+
+	// The most specific matching group wins; all "*" groups count as one
+	var best *group
+	bestLen := 0
+	policy := &robotsPolicy{}
+	for _, g := range groups {
+		for _, a := range g.agents {
+			switch {
+			case a == "*":
+				policy.rules = append(policy.rules, g.policy.rules...)
+				if g.policy.crawlDelay > policy.crawlDelay {
+					policy.crawlDelay = g.policy.crawlDelay
+				}
+			case strings.Contains(agent, a) && len(a) > bestLen:
+				best, bestLen = g, len(a)
+			}
+		}
+	}
+	if best != nil {
+		return &best.policy
+	}
+	return policy
+}
+
+// robotsPattern compiles a path pattern with * wildcards and an optional $ end anchor
+func robotsPattern(p string) *regexp.Regexp {
+	anchored := strings.HasSuffix(p, "$")
+	p = strings.TrimSuffix(p, "$")
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(p), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// allowed applies the longest matching rule, preferring Allow on a tie
+func (p *robotsPolicy) allowed(path string) bool {
+	allow, best := true, -1
+	for _, r := range p.rules {
+		if !r.pattern.MatchString(path) {
+			continue
+		}
+		if r.length > best || (r.length == best && r.allow) {
+			allow, best = r.allow, r.length
+		}
+	}
+	return allow
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestRobotsUnreachableIsAnError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL + "/d/some-article/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = checkRobots(context.Background(), srv.Client(), u)
+	if !errors.Is(err, errRobotsUnreachable) || errors.Is(err, errRobotsDisallowed) {
+		t.Fatalf("checkRobots = %v, want an errRobotsUnreachable error", err)
+	}
+
+	var status SourceStatus
+	status.addError(err)
+	if len(status.Errors) != 1 || len(status.Warnings) != 0 {
+		t.Errorf("addError filed %d errors and %d warnings, want 1 error", len(status.Errors), len(status.Warnings))
+	}
+}
+
+func TestRobotsCancelledFetchIsNotCached(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		w.Write([]byte("User-agent: *\nAllow: /\n"))
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL + "/d/some-article/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One caller fetches robots.txt and gives up; another waits behind it
+	// and gives up too, without waiting for the fetch
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { done <- checkRobots(ctx, srv.Client(), u) }()
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-done:
+			if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, errRobotsUnreachable) {
+				t.Errorf("checkRobots = %v, want the context's error", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("checkRobots kept waiting after its context ended")
+		}
+	}
+
+	// The next check fetches robots.txt again instead of reusing the failure
+	close(release)
+	if err := checkRobots(context.Background(), srv.Client(), u); err != nil {
+		t.Errorf("checkRobots after a cancelled fetch = %v, want nil", err)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	rulesFileName = "rules.json"
+	// rulesVersion is the newest rules file layout this build understands
+	rulesVersion = 1
+)
+
+// Rules shipped with the app, used when no override is configured and as
+// the fallback when an override fails to load
+//
+//go:embed rules.json
+var defaultRulesJSON []byte
+
+// ScraperRules are the selectors and patterns the scrapers use to read
+// third-party pages, kept out of the code so markup changes can be fixed
+// by editing a file
+type ScraperRules struct {
+	Version int              `json:"version"`
+	Rules   map[string]*Rule `json:"rules"`
+	// Where the rules were loaded from, for logs
+	origin string
+}
+
+// Rule is one lookup. Selector rules are CSS selectors and pattern rules
+// are regular expressions; either way they're tried in order and the first
+// that matches wins, so later entries act as fallbacks.
+type Rule struct {
+	Description string     `json:"description,omitempty"`
+	Selectors   []string   `json:"selectors,omitempty"`
+	Patterns    []string   `json:"patterns,omitempty"`
+	Tests       []RuleTest `json:"tests,omitempty"`
+
+	compiled []*regexp.Regexp
+}
+
+// RuleTest is a self-check for a rule: applied to HTML (selector rules) or
+// Text (pattern rules) it must produce exactly Want, in order. Selector
+// matches are compared by href, or by text for elements without one.
+type RuleTest struct {
+	HTML string   `json:"html,omitempty"`
+	Text string   `json:"text,omitempty"`
+	Want []string `json:"want"`
+}
+
+// Rules every rules file must define
+var requiredRules = []string{
+	"free_article_links",
+	"news_links",
+	"listing_links",
+	"next_page_links",
+	"listing_original_price",
+	"expiry",
+}
+
+var scraperRules = mustParseRules(defaultRulesJSON, "built-in")
+
+func mustParseRules(data []byte, origin string) *ScraperRules {
+	r, err := parseRules(data, origin)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// parseRules decodes a rules file and checks that it is complete and that
+// every pattern compiles
+func parseRules(data []byte, origin string) (*ScraperRules, error) {
+	r := &ScraperRules{origin: origin}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("%s: %w", origin, err)
+	}
+	if r.Version < 1 || r.Version > rulesVersion {
+		return nil, fmt.Errorf("%s: unsupported rules version %d (want 1-%d)", origin, r.Version, rulesVersion)
+	}
+	for _, name := range requiredRules {
+		if r.Rules[name] == nil {
+			return nil, fmt.Errorf("%s: missing rule %q", origin, name)
+		}
+	}
+	for name, rule := range r.Rules {
+		if len(rule.Selectors) == 0 && len(rule.Patterns) == 0 {
+			return nil, fmt.Errorf("%s: rule %q has no selectors or patterns", origin, name)
+		}
+		for _, p := range rule.Patterns {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return nil, fmt.Errorf("%s: rule %q: %w", origin, name, err)
+			}
+			rule.compiled = append(rule.compiled, re)
+		}
+	}
+	return r, nil
+}
+
+// loadRules replaces the built-in rules with the override file, if one is
+// configured or present in the data dir. Override files must pass their own
+// tests; otherwise the built-in rules stay in effect.
+func loadRules() error {
+	path := config.RulesFile
+	if path == "" {
+		path = filepath.Join(dataDir, rulesFileName)
+		if _, err := os.Stat(path); err != nil {
+			return nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	r, err := parseRules(data, path)
+	if err != nil {
+		return err
+	}
+	if failures := r.runTests(); len(failures) > 0 {
+		return fmt.Errorf("%s: %d rule tests failed, first: %s", path, len(failures), failures[0])
+	}
+	scraperRules = r
+	log.Printf("Loaded scraper rules from %s", path)
+	return nil
+}
+
+// rule returns the named rule, which parseRules guarantees exists for
+// everything in requiredRules
+func (r *ScraperRules) rule(name string) *Rule {
+	return r.Rules[name]
+}
+
+// find returns the matches of the first selector that matches anything in
+// sel, and which selector that was
+func (rule *Rule) find(sel *goquery.Selection) (*goquery.Selection, string) {
+	for _, s := range rule.Selectors {
+		if found := sel.Find(s); found.Length() > 0 {
+			return found, s
+		}
+	}
+	return sel.Find("__no_match__"), ""
+}
+
+// match returns the submatches of the first pattern that matches text, keyed
+// by group name, with the whole match under ""
+func (rule *Rule) match(text string) map[string]string {
+	for _, re := range rule.compiled {
+		m := re.FindStringSubmatch(text)
+		if m == nil {
+			continue
+		}
+		groups := make(map[string]string)
+		for i, name := range re.SubexpNames() {
+			if i == 0 || name != "" {
+				groups[name] = m[i]
+			}
+		}
+		return groups
+	}
+	return nil
+}
+
+// ruleValue is what selector tests compare: the href, or else the text
+func ruleValue(s *goquery.Selection) string {
+	if href, ok := s.Attr("href"); ok {
+		return href
+	}
+	return strings.TrimSpace(s.Text())
+}
+
+// runTests runs every rule's self-checks and describes the failures
+func (r *ScraperRules) runTests() []string {
+	var failures []string
+	for _, name := range r.ruleNames() {
+		rule := r.Rules[name]
+		for i, test := range rule.Tests {
+			got, err := rule.apply(test)
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s test %d: %v", name, i+1, err))
+			} else if strings.Join(got, "\n") != strings.Join(test.Want, "\n") {
+				failures = append(failures, fmt.Sprintf("%s test %d: got %q, want %q", name, i+1, got, test.Want))
+			}
+		}
+	}
+	return failures
+}
+
+func (rule *Rule) apply(test RuleTest) ([]string, error) {
+	var got []string
+	if len(rule.Selectors) > 0 {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(test.HTML))
+		if err != nil {
+			return nil, err
+		}
+		found, _ := rule.find(doc.Selection)
+		found.Each(func(i int, s *goquery.Selection) {
+			got = append(got, ruleValue(s))
+		})
+		return got, nil
+	}
+	if m := rule.match(test.Text); m != nil {
+		got = append(got, m[""])
+	}
+	return got, nil
+}
+
+func (r *ScraperRules) ruleNames() []string {
+	var names []string
+	for name := range r.Rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
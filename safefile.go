@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Backups of seen_assets.json are rotated at most this often, so they
+// reach back days rather than a few checks
+const backupInterval = 24 * time.Hour
+
+// writeFileAtomic replaces path with data so that a crash or full disk
+// leaves either the old or the new contents, never a mix: it writes a temp
+// file next to path, flushes it to disk and renames it over path.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// syncDir flushes a rename to disk. Not every platform can open a
+// directory for this (Windows can't), so failures are ignored.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// backupName is the name of the nth backup of path, newest first
+func backupName(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// rotateBackups copies path to path.1, shifting older backups up to
+// path.<keep>, unless path.1 is younger than backupInterval. The file
+// itself is copied, not moved, so it's there at every step.
+func rotateBackups(path string, keep int) error {
+	if keep < 1 {
+		return nil
+	}
+	if info, err := os.Stat(backupName(path, 1)); err == nil && time.Since(info.ModTime()) < backupInterval {
+		return nil
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	os.Remove(backupName(path, keep))
+	for n := keep - 1; n >= 1; n-- {
+		if err := os.Rename(backupName(path, n), backupName(path, n+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return copyFileAtomic(path, backupName(path, 1))
+}
+
+// copyFileAtomic copies src to dst via writeFileAtomic
+func copyFileAtomic(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(dst, data, 0644)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteFileAtomicFailureKeepsOldFile(t *testing.T) {
+	dir := t.TempDir()
+	// Long enough that the temp file's name can't be created, even by root
+	path := filepath.Join(dir, strings.Repeat("a", 250))
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFileAtomic(path, []byte("new"), 0644); err == nil {
+		t.Fatal("write succeeded; the test can't make it fail here")
+	}
+	if got, err := os.ReadFile(path); err != nil || string(got) != "old" {
+		t.Errorf("after a failed write the file holds %q (%v), want \"old\"", got, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("%d files left in the directory, want just the original", len(entries))
+	}
+}
+
+func TestRotateBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), dataFileName)
+	const keep = 2
+	old := time.Now().Add(-2 * backupInterval)
+	for _, contents := range []string{"1", "2", "3"} {
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := rotateBackups(path, keep); err != nil {
+			t.Fatal(err)
+		}
+		// As if a day had passed before the next save
+		os.Chtimes(backupName(path, 1), old, old)
+	}
+
+	for n, want := range map[int]string{1: "3", 2: "2"} {
+		if got, err := os.ReadFile(backupName(path, n)); err != nil || string(got) != want {
+			t.Errorf("backup %d holds %q (%v), want %q", n, got, err, want)
+		}
+	}
+	if _, err := os.Stat(backupName(path, keep+1)); !os.IsNotExist(err) {
+		t.Errorf("more than %d backups kept", keep)
+	}
+
+	// A save within a day of the last backup leaves the backups alone
+	os.Chtimes(backupName(path, 1), time.Now(), time.Now())
+	os.WriteFile(path, []byte("4"), 0644)
+	if err := rotateBackups(path, keep); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(backupName(path, 1)); string(got) != "3" {
+		t.Errorf("backup 1 replaced within %v: holds %q", backupInterval, got)
+	}
+}
+
+func TestJSONStoreRefusesToOverwriteUnreadableFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), dataFileName)
+	damaged := []byte(`{"version": 5, "seen_assets": {"https://www.fab.com/listings/a": {"title": "Cut o`)
+	if err := os.WriteFile(path, damaged, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &jsonStore{path: path, backups: 3}
+	if _, err := s.Load(); err == nil {
+		t.Fatal("damaged file loaded without an error")
+	}
+	if err := s.Save(&AppData{Version: dataVersion, SeenAssets: make(map[string]Asset)}); err == nil {
+		t.Error("saved over a file that couldn't be read")
+	}
+	if got, _ := os.ReadFile(path); string(got) != string(damaged) {
+		t.Errorf("damaged file changed to %q", got)
+	}
+	if _, err := os.Stat(backupName(path, 1)); !os.IsNotExist(err) {
+		t.Error("backups rotated although nothing was saved")
+	}
+}
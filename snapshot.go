@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Record/replay of HTTP traffic. In record mode every response the app
+// receives is saved to a snapshot directory; in replay mode responses are
+// served from such a directory instead of the network, so a misbehaving
+// check can be reproduced offline.
+
+// snapshot is one recorded response, stored as a JSON file
+type snapshot struct {
+	URL       string      `json:"url"`
+	Method    string      `json:"method"`
+	Status    int         `json:"status"`
+	Header    http.Header `json:"header"`
+	Body      string      `json:"body,omitempty"`
+	BodyBase  string      `json:"body_base64,omitempty"` // for bodies that aren't UTF-8
+	FetchedAt time.Time   `json:"fetched_at"`
+}
+
+func (s *snapshot) body() ([]byte, error) {
+	if s.BodyBase != "" {
+		return base64.StdEncoding.DecodeString(s.BodyBase)
+	}
+	return []byte(s.Body), nil
+}
+
+// recordingTransport saves every response passing through it
+type recordingTransport struct {
+	base http.RoundTripper
+	dir  string
+
+	mu  sync.Mutex
+	seq int
+}
+
+func newRecordingTransport(base http.RoundTripper, dir string) (*recordingTransport, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	log.Printf("Recording HTTP traffic to %s", dir)
+	return &recordingTransport{base: base, dir: dir}, nil
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	snap := snapshot{
+		URL:       req.URL.String(),
+		Method:    req.Method,
+		Status:    resp.StatusCode,
+		Header:    resp.Header,
+		FetchedAt: time.Now(),
+	}
+	if utf8.Valid(body) {
+		snap.Body = string(body)
+	} else {
+		snap.BodyBase = base64.StdEncoding.EncodeToString(body)
+	}
+	if err := t.save(&snap); err != nil {
+		log.Printf("Snapshot error: %v", err)
+	}
+	return resp, nil
+}
+
+func (t *recordingTransport) save(snap *snapshot) error {
+	t.mu.Lock()
+	t.seq++
+	seq := t.seq
+	t.mu.Unlock()
+
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Sortable by time, then by order within the run
+	name := fmt.Sprintf("%s-%04d-%s.json", snap.FetchedAt.Format("20060102-150405"), seq, urlHash(snap.URL))
+	return os.WriteFile(filepath.Join(t.dir, name), data, 0644)
+}
+
+func urlHash(url string) string {
+	sum := sha1.Sum([]byte(url))
+	return hex.EncodeToString(sum[:6])
+}
+
+// replayTransport answers requests from recorded snapshots. A URL recorded
+// several times is replayed in recording order, repeating the last one.
+// URLs that were never recorded get a 404.
+type replayTransport struct {
+	mu        sync.Mutex
+	snapshots map[string][]*snapshot
+	served    map[string]int
+}
+
+func newReplayTransport(dir string) (*replayTransport, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no snapshots in %s", dir)
+	}
+	sort.Strings(files)
+
+	t := &replayTransport{snapshots: make(map[string][]*snapshot), served: make(map[string]int)}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		snap := &snapshot{}
+		if err := json.Unmarshal(data, snap); err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		key := snap.Method + " " + snap.URL
+		t.snapshots[key] = append(t.snapshots[key], snap)
+	}
+	log.Printf("Replaying HTTP traffic from %s (%d snapshots)", dir, len(files))
+	return t, nil
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.Method + " " + req.URL.String()
+
+	t.mu.Lock()
+	snaps := t.snapshots[key]
+	n := t.served[key]
+	t.served[key]++
+	t.mu.Unlock()
+
+	if len(snaps) == 0 {
+		log.Printf("Replay: no snapshot for %s", key)
+		return &http.Response{
+			StatusCode: http.StatusNotFound,
+			Status:     "404 Not Found",
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{"X-Replay-Missing": {"1"}},
+			Body:       io.NopCloser(strings.NewReader("")),
+			Request:    req,
+		}, nil
+	}
+	if n >= len(snaps) {
+		n = len(snaps) - 1
+	}
+	snap := snaps[n]
+
+	body, err := snap.body()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode:    snap.Status,
+		Status:        fmt.Sprintf("%d %s", snap.Status, http.StatusText(snap.Status)),
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        snap.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRecordingReplaysByteIdentically(t *testing.T) {
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("ETag", `"v1"`)
+			fmt.Fprint(w, "<html><body>Früh · 免费</body></html>\n")
+		case "/thumbnail":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte{0x89, 'P', 'N', 'G', 0xff, 0x00, 0xfe})
+		case "/busy":
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/counter":
+			fmt.Fprintf(w, "visit %d", hits.Add(1))
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	rec, err := newRecordingTransport(http.DefaultTransport, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type response struct {
+		status int
+		header http.Header
+		body   []byte
+	}
+	get := func(client *http.Client, path string) response {
+		t.Helper()
+		resp, err := client.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response{resp.StatusCode, resp.Header, body}
+	}
+
+	paths := []string{"/page", "/thumbnail", "/busy", "/counter", "/counter"}
+	recorded := make([]response, len(paths))
+	client := &http.Client{Transport: rec}
+	for i, path := range paths {
+		recorded[i] = get(client, path)
+	}
+
+	replay, err := newReplayTransport(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = &http.Client{Transport: replay}
+	for i, path := range paths {
+		got, want := get(client, path), recorded[i]
+		if got.status != want.status || !bytes.Equal(got.body, want.body) {
+			t.Errorf("%s (request %d): replayed %d %q, recorded %d %q", path, i, got.status, got.body, want.status, want.body)
+		}
+		for _, name := range []string{"Content-Type", "ETag", "Retry-After"} {
+			if got.header.Get(name) != want.header.Get(name) {
+				t.Errorf("%s: replayed %s %q, recorded %q", path, name, got.header.Get(name), want.header.Get(name))
+			}
+		}
+	}
+	// Past the end of the recording the last response repeats
+	if got := get(client, "/counter"); string(got.body) != "visit 2" {
+		t.Errorf("third /counter replayed %q, want the last recorded one", got.body)
+	}
+}
+
+func TestReplayMissingRecording(t *testing.T) {
+	if _, err := newReplayTransport(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("replaying a directory that doesn't exist succeeded")
+	}
+	if _, err := newReplayTransport(t.TempDir()); err == nil {
+		t.Error("replaying an empty directory succeeded")
+	}
+
+	damaged := t.TempDir()
+	os.WriteFile(filepath.Join(damaged, "20250107-150000-0001-abc.json"), []byte(`{"url": "https://`), 0644)
+	if _, err := newReplayTransport(damaged); err == nil {
+		t.Error("replaying a damaged snapshot succeeded")
+	}
+
+	dir := t.TempDir()
+	rec := &recordingTransport{dir: dir}
+	if err := rec.save(&snapshot{URL: "https://unrealsource.com/dispatch/", Method: "GET", Status: http.StatusOK, Body: "<html></html>"}); err != nil {
+		t.Fatal(err)
+	}
+	replay, err := newReplayTransport(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("GET", "https://unrealsource.com/d/not-recorded/", nil)
+	resp, err := replay.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound || resp.Header.Get("X-Replay-Missing") == "" {
+		t.Errorf("unrecorded URL replayed as %d %v, want a 404 marked missing", resp.StatusCode, resp.Header)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Source is a feed of assets that checkForAssets polls on every run.
+type Source interface {
+	// Name identifies the source in logs, status and configuration.
+	Name() string
+	// Fetch scrapes the source. A non-nil error means the whole fetch failed;
+	// partial failures are reported in FetchResult.Errors.
+	Fetch(ctx context.Context) (*FetchResult, error)
+}
+
+// FetchResult holds everything a single source fetch produced
+type FetchResult struct {
+	Free   []Asset
+	Latest []Asset
+	Games  []Asset
+	// Permanent holds listings that are free for good
+	Permanent []Asset
+	Batches   []Batch
+	Errors    []error
+	// NotModified is set when the source's pages were unchanged since the
+	// last check, so nothing was parsed
+	NotModified bool
+	// UnchangedPages lists sub-pages skipped because they were unchanged
+	UnchangedPages []string
+	// Complete is set when the result holds everything the source offers,
+	// so an asset it listed before and no longer does was removed
+	Complete bool
+	// RuleMatches counts the elements each scraper rule matched, for
+	// breakage detection
+	RuleMatches map[string]int
+}
+
+// found is the number of assets of any category in the result
+func (r *FetchResult) found() int {
+	return len(r.Free) + len(r.Latest) + len(r.Games) + len(r.Permanent)
+}
+
+// countRule adds n matches of a scraper rule to the result
+func (r *FetchResult) countRule(rule string, n int) {
+	if r.RuleMatches == nil {
+		r.RuleMatches = make(map[string]int)
+	}
+	r.RuleMatches[rule] += n
+}
+
+// Backfiller is implemented by sources that can import past items by
+// walking their archive
+type Backfiller interface {
+	Backfill(ctx context.Context, maxPages int) (*FetchResult, error)
+}
+
+// SourceStatus records the outcome of the most recent fetch from a source
+type SourceStatus struct {
+	LastRun     time.Time `json:"last_run"`
+	Found       int       `json:"found"`
+	NotModified bool      `json:"not_modified,omitempty"`
+	Requests    int       `json:"requests"`
+	Retries     int       `json:"retries,omitempty"`
+	Errors      []string  `json:"errors,omitempty"`
+	// Pages skipped because robots.txt disallows them
+	Warnings []string `json:"warnings,omitempty"`
+	// Why the last run looks like the scraper no longer fits the site
+	Broken string `json:"broken,omitempty"`
+	// When the source last listed its assets, including unchanged listings
+	Listed time.Time `json:"listed"`
+}
+
+// addError records a fetch error. Pages robots.txt keeps us out of are
+// warnings rather than failures: nothing is wrong, but items may be missed.
+// A robots.txt that couldn't be fetched is a failure like any other.
+func (s *SourceStatus) addError(err error) {
+	if errors.Is(err, errRobotsDisallowed) {
+		s.Warnings = append(s.Warnings, err.Error())
+		return
+	}
+	s.Errors = append(s.Errors, err.Error())
+}
+
+var (
+	sourceRegistry  []Source
+	disabledSources = make(map[string]bool)
+)
+
+// registerSource adds a source to the registry. Names must be unique.
+func registerSource(s Source) {
+	for _, existing := range sourceRegistry {
+		if existing.Name() == s.Name() {
+			panic(fmt.Sprintf("source %q registered twice", s.Name()))
+		}
+	}
+	sourceRegistry = append(sourceRegistry, s)
+}
+
+// registerDefaultSources registers the built-in sources
+func registerDefaultSources() {
+	registerSource(newUnrealSource(config.DispatchURL, httpClient))
+	registerSource(newEpicGamesSource(config.EpicPromotionsURL, httpClient))
+	registerSource(newFabSearchSource(config.FabSearchURL, httpClient))
+}
+
+// applySourceConfig disables the sources listed in the configuration
+func applySourceConfig() {
+	disabledSources = make(map[string]bool)
+	for _, name := range config.DisabledSources {
+		disabledSources[name] = true
+	}
+}
+
+func enabledSources() []Source {
+	var enabled []Source
+	for _, s := range sourceRegistry {
+		if !disabledSources[s.Name()] {
+			enabled = append(enabled, s)
+		}
+	}
+	return enabled
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// epicGamesSource reads the Epic Games Store's weekly free games from the
+// promotions JSON the store's own free games page uses
+type epicGamesSource struct {
+	promotionsURL string
+	client        *http.Client
+	now           func() time.Time
+}
+
+func newEpicGamesSource(promotionsURL string, client *http.Client) *epicGamesSource {
+	return &epicGamesSource{promotionsURL: promotionsURL, client: client, now: time.Now}
+}
+
+func (s *epicGamesSource) Name() string { return "epicgames" }
+
+// The parts of the freeGamesPromotions response we use
+type epicPromotions struct {
+	Data struct {
+		Catalog struct {
+			SearchStore struct {
+				Elements []epicOffer `json:"elements"`
+			} `json:"searchStore"`
+		} `json:"Catalog"`
+	} `json:"data"`
+}
+
+type epicOffer struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	OfferType   string `json:"offerType"`
+	ProductSlug string `json:"productSlug"`
+	URLSlug     string `json:"urlSlug"`
+	Seller      struct {
+		Name string `json:"name"`
+	} `json:"seller"`
+	KeyImages []struct {
+		Type string `json:"type"`
+		URL  string `json:"url"`
+	} `json:"keyImages"`
+	CatalogNs struct {
+		Mappings []epicPageMapping `json:"mappings"`
+	} `json:"catalogNs"`
+	OfferMappings []epicPageMapping `json:"offerMappings"`
+	Price         struct {
+		TotalPrice struct {
+			OriginalPrice int `json:"originalPrice"`
+			FmtPrice      struct {
+				OriginalPrice string `json:"originalPrice"`
+			} `json:"fmtPrice"`
+		} `json:"totalPrice"`
+	} `json:"price"`
+	Promotions *struct {
+		PromotionalOffers         []epicPromotionSet `json:"promotionalOffers"`
+		UpcomingPromotionalOffers []epicPromotionSet `json:"upcomingPromotionalOffers"`
+	} `json:"promotions"`
+}
+
+type epicPageMapping struct {
+	PageSlug string `json:"pageSlug"`
+	PageType string `json:"pageType"`
+}
+
+type epicPromotionSet struct {
+	PromotionalOffers []epicPromotion `json:"promotionalOffers"`
+}
+
+type epicPromotion struct {
+	StartDate       time.Time `json:"startDate"`
+	EndDate         time.Time `json:"endDate"`
+	DiscountSetting struct {
+		DiscountType       string `json:"discountType"`
+		DiscountPercentage int    `json:"discountPercentage"`
+	} `json:"discountSetting"`
+}
+
+func (s *epicGamesSource) Fetch(ctx context.Context) (*FetchResult, error) {
+	resp, err := fetch(ctx, s.client, s.promotionsURL)
+	if err == errNotModified {
+		log.Println("Epic free games unchanged since last check")
+		return &FetchResult{NotModified: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var promos epicPromotions
+	if err := json.NewDecoder(resp.Body).Decode(&promos); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.promotionsURL, err)
+	}
+
+	// The promotions feed is every free game there is
+	result := &FetchResult{Complete: true}
+	now := s.now()
+	for _, offer := range promos.Data.Catalog.SearchStore.Elements {
+		promo, ok := offer.freePromotion()
+		if !ok || (!promo.EndDate.IsZero() && now.After(promo.EndDate)) {
+			continue
+		}
+		slug := offer.pageSlug()
+		if slug == "" {
+			// Mystery games have no store page until they're revealed
+			continue
+		}
+		result.Games = append(result.Games, offer.asset(slug, promo))
+	}
+
+	log.Printf("Epic Games Store: %d free games", len(result.Games))
+	return result, nil
+}
+
+// freePromotion returns the current or upcoming promotion that makes the
+// offer free, preferring the current one
+func (o *epicOffer) freePromotion() (epicPromotion, bool) {
+	if o.Promotions == nil {
+		return epicPromotion{}, false
+	}
+	for _, sets := range [][]epicPromotionSet{o.Promotions.PromotionalOffers, o.Promotions.UpcomingPromotionalOffers} {
+		for _, set := range sets {
+			for _, p := range set.PromotionalOffers {
+				if p.DiscountSetting.DiscountPercentage == 0 {
+					return p, true
+				}
+			}
+		}
+	}
+	return epicPromotion{}, false
+}
+
+// pageSlug finds the store page of an offer
+func (o *epicOffer) pageSlug() string {
+	for _, mappings := range [][]epicPageMapping{o.OfferMappings, o.CatalogNs.Mappings} {
+		for _, m := range mappings {
+			if m.PageType == "productHome" && m.PageSlug != "" {
+				return m.PageSlug
+			}
+		}
+	}
+	if slug := strings.TrimSuffix(o.ProductSlug, "/home"); slug != "" && slug != "[]" {
+		return slug
+	}
+	if o.URLSlug != "" && !strings.Contains(o.URLSlug, "mystery") {
+		return o.URLSlug
+	}
+	return ""
+}
+
+func (o *epicOffer) asset(slug string, promo epicPromotion) Asset {
+	path := "/p/"
+	if o.OfferType == "BUNDLE" {
+		path = "/bundles/"
+	}
+	a := Asset{
+		Title:       strings.TrimSpace(o.Title),
+		URL:         canonicalURL("https://store.epicgames.com" + path + slug),
+		Price:       "FREE",
+		Category:    CategoryGames,
+		StartsAt:    promo.StartDate,
+		ExpiresAt:   promo.EndDate,
+		Seller:      strings.TrimSpace(o.Seller.Name),
+		Description: strings.TrimSpace(o.Description),
+	}
+	if o.Price.TotalPrice.OriginalPrice > 0 {
+		a.OriginalPrice = o.Price.TotalPrice.FmtPrice.OriginalPrice
+	}
+	for _, imageType := range []string{"OfferImageWide", "Thumbnail", "DieselStoreFrontWide"} {
+		for _, img := range o.KeyImages {
+			if img.Type == imageType && a.Thumbnail == "" {
+				a.Thumbnail = img.URL
+			}
+		}
+	}
+	return a
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// fabSearchSource polls Fab's listing search, filtered to free listings and
+// sorted newest first, for content that is free for good. It reads the JSON
+// behind fab.com/search, so new listings show up without waiting for a
+// dispatch article.
+type fabSearchSource struct {
+	searchURL string
+	client    *http.Client
+}
+
+func newFabSearchSource(searchURL string, client *http.Client) *fabSearchSource {
+	return &fabSearchSource{searchURL: searchURL, client: client}
+}
+
+func (s *fabSearchSource) Name() string { return "fab-search" }
+
+// The parts of a Fab search response we use
+type fabSearchResponse struct {
+	Results []fabSearchListing `json:"results"`
+}
+
+type fabSearchListing struct {
+	UID          string `json:"uid"`
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	IsFree       bool   `json:"isFree"`
+	IsDiscounted bool   `json:"isDiscounted"`
+	CreatedAt    string `json:"createdAt"`
+	User         struct {
+		SellerName string `json:"sellerName"`
+	} `json:"user"`
+	Category struct {
+		Name string `json:"name"`
+	} `json:"category"`
+	Thumbnails []struct {
+		MediaURL string `json:"mediaUrl"`
+	} `json:"thumbnails"`
+	StartingPrice *struct {
+		Price           float64 `json:"price"`
+		DiscountedPrice float64 `json:"discountedPrice"`
+	} `json:"startingPrice"`
+}
+
+func (s *fabSearchSource) Fetch(ctx context.Context) (*FetchResult, error) {
+	resp, err := fetch(ctx, s.client, s.searchURL)
+	if err == errNotModified {
+		log.Println("Fab free listings unchanged since last check")
+		return &FetchResult{NotModified: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var search fabSearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&search); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.searchURL, err)
+	}
+
+	result := &FetchResult{}
+	for _, l := range search.Results {
+		if l.UID == "" || strings.TrimSpace(l.Title) == "" || !l.isPermanentlyFree() {
+			continue
+		}
+		result.Permanent = append(result.Permanent, l.asset())
+	}
+
+	log.Printf("Fab search: %d permanently free listings", len(result.Permanent))
+	return result, nil
+}
+
+// isPermanentlyFree tells listings that are always free from paid ones in a
+// limited-time free promotion, which the dispatch source reports
+func (l *fabSearchListing) isPermanentlyFree() bool {
+	if l.IsDiscounted {
+		return false
+	}
+	if p := l.StartingPrice; p != nil {
+		return p.Price == 0
+	}
+	return l.IsFree
+}
+
+func (l *fabSearchListing) asset() Asset {
+	a := Asset{
+		Title:           strings.TrimSpace(l.Title),
+		URL:             canonicalURL("https://www.fab.com/listings/" + l.UID),
+		Price:           "FREE",
+		Category:        CategoryPermanent,
+		Seller:          strings.TrimSpace(l.User.SellerName),
+		ListingCategory: strings.TrimSpace(l.Category.Name),
+		Description:     strings.TrimSpace(l.Description),
+	}
+	if t, err := time.Parse(time.RFC3339, l.CreatedAt); err == nil {
+		a.Published, a.PublishedPrecision = t, precisionExact
+	}
+	if len(l.Thumbnails) > 0 {
+		a.Thumbnail = l.Thumbnails[0].MediaURL
+	}
+	return a
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// unrealSource scrapes the unrealsource.com dispatch for free FAB assets and news
+type unrealSource struct {
+	dispatchURL string
+	client      *http.Client
+	now         func() time.Time // anchors "3 days ago" timestamps
+}
+
+func newUnrealSource(dispatchURL string, client *http.Client) *unrealSource {
+	return &unrealSource{dispatchURL: dispatchURL, client: client, now: time.Now}
+}
+
+func (s *unrealSource) Name() string { return "unrealsource" }
+
+func (s *unrealSource) Fetch(ctx context.Context) (*FetchResult, error) {
+	result := &FetchResult{}
+	seenURLs := make(map[string]bool)
+
+	// First, get the dispatch page to find links to free asset announcements
+	doc, err := fetchDocument(ctx, s.client, s.dispatchURL)
+	if err == errNotModified {
+		log.Println("Dispatch page unchanged since last check")
+		return &FetchResult{NotModified: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Find links to "free fab assets" detail pages
+	freeDispatchLinks := findFreeDispatchLinks(doc)
+	result.countRule("free_article_links", len(freeDispatchLinks))
+
+	// Fetch every free assets detail page; batches can overlap and the
+	// newest link isn't necessarily first in the DOM. Pages are fetched in
+	// parallel but parsed in link order, so deduping stays stable.
+	detailCtx, cancel := detailContext(ctx)
+	defer cancel()
+	docs, errs := s.fetchArticles(detailCtx, freeDispatchLinks)
+	for i, link := range freeDispatchLinks {
+		if errs[i] == errNotModified {
+			result.UnchangedPages = append(result.UnchangedPages, link)
+			continue
+		}
+		if errs[i] != nil {
+			log.Printf("Error fetching free assets page: %v", errs[i])
+			result.Errors = append(result.Errors, errs[i])
+			continue
+		}
+		free, batch, linkErrs := s.parseFreeAssetsPage(detailCtx, docs[i], link, seenURLs)
+		result.Free = append(result.Free, free...)
+		result.Errors = append(result.Errors, linkErrs...)
+		result.Batches = append(result.Batches, batch)
+		result.countRule("listing_links", len(batch.AssetURLs))
+	}
+
+	// Collect other dispatch links as "latest" news
+	newsLinks, _ := scraperRules.rule("news_links").find(doc.Selection)
+	result.countRule("news_links", newsLinks.Length())
+	fetchedAt := s.now()
+	latestIndex := make(map[string]int)
+	newsLinks.Each(func(i int, link *goquery.Selection) {
+		href, exists := link.Attr("href")
+		if !exists {
+			return
+		}
+		href = resolveHref(doc, href)
+		text := strings.TrimSpace(link.Text())
+		published, precision, isTime := parseRelativeTime(text, fetchedAt)
+
+		// Articles are often linked twice, once by title and once by timestamp
+		if i, ok := latestIndex[href]; ok {
+			a := &result.Latest[i]
+			if isTime && a.Published.IsZero() {
+				a.Published, a.PublishedPrecision = published, precision
+			} else if !isTime && a.TitleGuessed && len(text) >= 5 {
+				a.Title, a.TitleGuessed = shortTitle(text), false
+			}
+			return
+		}
+		// Skip free-fab-assets pages (already processed)
+		if seenURLs[href] || containsString(freeDispatchLinks, href) {
+			return
+		}
+
+		// Use the link text as title unless it's a timestamp, then fall back
+		// to the URL. Guessed titles are replaced by the article's own title
+		// when it's fetched.
+		title, guessed := text, false
+		if isTime || len(title) < 5 {
+			title, guessed = titleFromSlug(href), true
+		}
+		if title == "" || len(title) < 5 {
+			return
+		}
+
+		asset := Asset{
+			Title:        shortTitle(title),
+			TitleGuessed: guessed,
+			URL:          href,
+			Price:        "News",
+			Category:     CategoryLatest,
+		}
+		if isTime {
+			asset.Published, asset.PublishedPrecision = published, precision
+		}
+		latestIndex[href] = len(result.Latest)
+		result.Latest = append(result.Latest, asset)
+	})
+
+	log.Printf("Scraped: %d free assets, %d latest assets", len(result.Free), len(result.Latest))
+	return result, nil
+}
+
+func shortTitle(title string) string {
+	if len(title) > 80 {
+		return title[:77] + "..."
+	}
+	return title
+}
+
+// titleFromSlug makes a title from an article URL: /d/some-article-title/ -> Some Article Title
+func titleFromSlug(href string) string {
+	parts := strings.Split(href, "/d/")
+	if len(parts) < 2 {
+		return ""
+	}
+	slug := strings.TrimSuffix(parts[1], "/")
+	slug = strings.ReplaceAll(slug, "-", " ")
+	// Capitalize first letter of each word
+	words := strings.Fields(slug)
+	for i, w := range words {
+		if len(w) > 0 {
+			words[i] = strings.ToUpper(string(w[0])) + w[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// Backfill walks older dispatch pages and imports the free assets of every
+// past batch, stamped with the date its article was published
+func (s *unrealSource) Backfill(ctx context.Context, maxPages int) (*FetchResult, error) {
+	// The archive is wanted in full, unchanged pages included
+	ctx = withoutHTTPCache(ctx)
+	result := &FetchResult{}
+	seenURLs := make(map[string]bool)
+	seenArticles := make(map[string]bool)
+	visitedPages := make(map[string]bool)
+
+	pageURL := s.dispatchURL
+	for page := 0; page < maxPages && pageURL != "" && !visitedPages[pageURL]; page++ {
+		visitedPages[pageURL] = true
+		doc, err := fetchDocument(ctx, s.client, pageURL)
+		if err != nil {
+			if page == 0 {
+				return nil, err
+			}
+			result.Errors = append(result.Errors, err)
+			break
+		}
+
+		var links []string
+		for _, link := range findFreeDispatchLinks(doc) {
+			if !seenArticles[link] {
+				seenArticles[link] = true
+				links = append(links, link)
+			}
+		}
+		docs, errs := s.fetchArticles(ctx, links)
+		for i, link := range links {
+			if errs[i] != nil {
+				result.Errors = append(result.Errors, errs[i])
+				continue
+			}
+			free, batch, linkErrs := s.parseFreeAssetsPage(ctx, docs[i], link, seenURLs)
+			result.Free = append(result.Free, free...)
+			result.Errors = append(result.Errors, linkErrs...)
+			result.Batches = append(result.Batches, batch)
+		}
+
+		pageURL = nextPageURL(doc)
+	}
+
+	log.Printf("Backfill: %d free assets from %d articles in %d pages", len(result.Free), len(seenArticles), len(visitedPages))
+	return result, nil
+}
+
+// findFreeDispatchLinks returns the deduped links to "free fab assets" articles
+func findFreeDispatchLinks(doc *goquery.Document) []string {
+	// These are dispatch articles with URLs like /d/free-fab-assets-*
+	links := []string{}
+	found, _ := scraperRules.rule("free_article_links").find(doc.Selection)
+	found.Each(func(i int, link *goquery.Selection) {
+		href, exists := link.Attr("href")
+		if !exists || href == "" {
+			return
+		}
+		href = resolveHref(doc, href)
+		// Dedupe
+		for _, existing := range links {
+			if existing == href {
+				return
+			}
+		}
+		links = append(links, href)
+	})
+	return links
+}
+
+// nextPageURL finds the link to the next (older) page of the dispatch
+func nextPageURL(doc *goquery.Document) string {
+	found, _ := scraperRules.rule("next_page_links").find(doc.Selection)
+	if href, ok := found.First().Attr("href"); ok && href != "" {
+		return resolveHref(doc, href)
+	}
+	next := ""
+	doc.Find("a[href]").EachWithBreak(func(i int, link *goquery.Selection) bool {
+		text := strings.ToLower(strings.TrimSpace(link.Text()))
+		if strings.HasPrefix(text, "older") || strings.HasPrefix(text, "next") || text == "load more" {
+			href, _ := link.Attr("href")
+			if href != "" && !strings.HasPrefix(href, "#") {
+				next = resolveHref(doc, href)
+				return false
+			}
+		}
+		return true
+	})
+	return next
+}
+
+// articleDate returns when a dispatch article was published, if the page says
+func articleDate(doc *goquery.Document) time.Time {
+	var candidates []string
+	for _, sel := range []string{
+		"meta[property='article:published_time']",
+		"meta[name='date']",
+		"meta[itemprop='datePublished']",
+	} {
+		if content, ok := doc.Find(sel).First().Attr("content"); ok {
+			candidates = append(candidates, content)
+		}
+	}
+	if dt, ok := doc.Find("time[datetime]").First().Attr("datetime"); ok {
+		candidates = append(candidates, dt)
+	}
+
+	for _, c := range candidates {
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, strings.TrimSpace(c)); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// fetchArticles fetches dispatch articles on the worker pool. The results
+// line up with urls: a document, or the error that page failed with.
+func (s *unrealSource) fetchArticles(ctx context.Context, urls []string) ([]*goquery.Document, []error) {
+	docs := make([]*goquery.Document, len(urls))
+	errs := make([]error, len(urls))
+	forEachLimit(ctx, config.Concurrency, len(urls), func(i int) {
+		docs[i], errs[i] = fetchDocument(ctx, s.client, urls[i])
+	})
+	for i := range urls {
+		if docs[i] == nil {
+			errs[i] = skippedError(ctx, errs[i], urls[i])
+		}
+	}
+	return docs, errs
+}
+
+// parseFreeAssetsPage returns the free assets listed in a dispatch article
+// that aren't in seenURLs yet, and the batch the article announces. Assets
+// behind links that couldn't be followed are left out, with the errors:
+// stored under the link, they'd turn up again as new once it resolves.
+func (s *unrealSource) parseFreeAssetsPage(ctx context.Context, doc *goquery.Document, url string, seenURLs map[string]bool) ([]Asset, Batch, []error) {
+	var assets []Asset
+	var errs []error
+
+	announced := articleDate(doc)
+
+	// Extract expiration date from page text
+	expiresAt, expiresText, _ := parseExpiry(doc.Text(), announced)
+
+	// Find all fab.com/listings links on this page - these are the free assets
+	batch := Batch{
+		ArticleURL: url,
+		Announced:  announced,
+		ValidFrom:  announced,
+		ValidUntil: expiresAt,
+	}
+	listingLinks, _ := scraperRules.rule("listing_links").find(doc.Selection)
+	listingLinks.Each(func(i int, link *goquery.Selection) {
+		href, exists := link.Attr("href")
+		if !exists {
+			return
+		}
+		href = resolveHref(doc, href)
+
+		title := strings.TrimSpace(link.Text())
+		if title == "" || len(title) < 3 {
+			return
+		}
+		if needsRedirect(href) {
+			resolved, err := resolveRedirect(ctx, s.client, href)
+			if err != nil {
+				log.Printf("Skipping %q until its link can be followed: %v", title, err)
+				errs = append(errs, err)
+				return
+			}
+			href = resolved
+		}
+
+		// Assets shared with another article still belong to this batch
+		if !containsString(batch.AssetURLs, href) {
+			batch.AssetURLs = append(batch.AssetURLs, href)
+		}
+		if seenURLs[href] {
+			return
+		}
+
+		seenURLs[href] = true
+		assets = append(assets, Asset{
+			Title:       title,
+			URL:         href,
+			Price:       "FREE",
+			Category:    CategoryFree,
+			ExpiresAt:   expiresAt,
+			ExpiresText: expiresText,
+			DispatchURL: url,
+			Announced:   announced,
+		})
+	})
+
+	log.Printf("Found %d free assets on detail page", len(assets))
+	return assets, batch, errs
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// redirectStub answers for every host: legacy marketplace products that
+// moved redirect to their Fab listing, the rest are gone
+type redirectStub map[string]string
+
+func (moved redirectStub) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp := &http.Response{
+		StatusCode: http.StatusNotFound,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}
+	if target, ok := moved[req.URL.Path]; ok {
+		resp.StatusCode = http.StatusMovedPermanently
+		resp.Header.Set("Location", target)
+	} else if strings.HasPrefix(req.URL.Path, "/listings/") {
+		resp.StatusCode = http.StatusOK
+	}
+	return resp, nil
+}
+
+func TestUnfollowedLinksAreSkipped(t *testing.T) {
+	savedLimit := rateLimitDisabled
+	rateLimitDisabled = true
+	t.Cleanup(func() { rateLimitDisabled = savedLimit })
+
+	const listing = "https://www.fab.com/listings/7b4c8a19-6d5e-4f80-9b7c-8d9e0f1a2b3c"
+	client := &http.Client{Transport: redirectStub{
+		"/marketplace/product/redirect-test-moved": listing,
+	}}
+	src := newUnrealSource("https://unrealsource.com/dispatch/", client)
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><body>
+<a href="https://www.unrealengine.com/marketplace/en-US/product/redirect-test-moved">Moved Asset</a>
+<a href="https://www.unrealengine.com/marketplace/en-US/product/redirect-test-unreachable">Unreachable Asset</a>
+</body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Url, _ = url.Parse("https://unrealsource.com/d/free-fab-assets-redirect-test/")
+
+	assets, batch, errs := src.parseFreeAssetsPage(context.Background(), doc, doc.Url.String(), make(map[string]bool))
+	if len(assets) != 1 || assets[0].URL != listing {
+		t.Fatalf("assets %+v, want only the moved one under %s", assets, listing)
+	}
+	if len(batch.AssetURLs) != 1 || batch.AssetURLs[0] != listing {
+		t.Errorf("batch lists %v, want only %s", batch.AssetURLs, listing)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "redirect-test-unreachable") {
+		t.Errorf("errors %v, want the unreachable link's", errs)
+	}
+}
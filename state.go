@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+)
+
+// appState guards the app's data. Background checks, the Check Now button,
+// the tray menu and the UI all reach it from their own goroutines, so every
+// access goes through a read or update transaction. Transactions can't be
+// nested, and network requests belong outside them: fetch first, then
+// apply the results in an update.
+type appState struct {
+	mu   sync.RWMutex
+	data AppData
+	// Last error loading or saving data, shown in the status bar until a
+	// save succeeds
+	storeErr error
+}
+
+var state appState
+
+// read calls fn with the data, which fn must not modify or keep
+func (s *appState) read(fn func(d *AppData)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	fn(&s.data)
+}
+
+// update calls fn with the data for changing it; no other transaction
+// runs meanwhile
+func (s *appState) update(fn func(d *AppData)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.data)
+}
+
+// load replaces the data with what the store has
+func (s *appState) load() error {
+	data, err := store.Load()
+	if data.SeenAssets == nil {
+		data.SeenAssets = make(map[string]Asset)
+	}
+	data.Version = dataVersion
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data = data
+	s.storeErr = nil
+	if err != nil {
+		s.storeErr = fmt.Errorf("couldn't load seen assets: %w", err)
+	}
+	return err
+}
+
+// save writes the data to the store. Saves are serialized with updates, so
+// each one writes a consistent snapshot.
+func (s *appState) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := store.Save(&s.data)
+	if err != nil {
+		err = fmt.Errorf("couldn't save seen assets: %w", err)
+	}
+	s.storeErr = err
+	return err
+}
+
+func (s *appState) storeError() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.storeErr
+}
+
+// checkGuard runs checks and backfills one at a time. A job requested
+// while another runs doesn't start next to it; it's queued to run after,
+// at most once per job however many requests come in meanwhile, so nothing
+// found after the running job's fetches is missed.
+type checkGuard struct {
+	mu      sync.Mutex
+	running bool
+	queue   []guardJob
+}
+
+type guardJob struct {
+	name string
+	fn   func()
+}
+
+var checks checkGuard
+
+// run calls fn, then the jobs queued during the call. If a job is already
+// running it only queues fn, unless a job of the same name is queued, and
+// reports false.
+func (g *checkGuard) run(name string, fn func()) bool {
+	g.mu.Lock()
+	if g.running {
+		queued := false
+		for _, j := range g.queue {
+			queued = queued || j.name == name
+		}
+		if !queued {
+			g.queue = append(g.queue, guardJob{name, fn})
+		}
+		g.mu.Unlock()
+		return false
+	}
+	g.running = true
+	g.mu.Unlock()
+
+	for {
+		g.call(name, fn)
+		g.mu.Lock()
+		if len(g.queue) == 0 {
+			g.running = false
+			g.mu.Unlock()
+			return true
+		}
+		name, fn = g.queue[0].name, g.queue[0].fn
+		g.queue = g.queue[1:]
+		g.mu.Unlock()
+	}
+}
+
+// call runs one job. A job that panics is logged and done with, so the jobs
+// queued behind it still run and later requests don't queue up forever
+// behind a guard that's never released.
+func (g *checkGuard) call(name string, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("%s panicked: %v\n%s", name, r, debug.Stack())
+		}
+	}()
+	fn()
+}
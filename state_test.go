@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// These tests are meant for the race detector: go test -race ./...
+
+const (
+	stressWorkers  = 8
+	stressRequests = 200
+)
+
+// useScratchStore points the app state at an empty JSON store in a temp dir
+func useScratchStore(t *testing.T) {
+	t.Helper()
+	savedStore := store
+	store = &jsonStore{path: filepath.Join(t.TempDir(), dataFileName)}
+	state.update(func(d *AppData) { *d = AppData{Version: dataVersion, SeenAssets: make(map[string]Asset)} })
+	t.Cleanup(func() {
+		store = savedStore
+		state.update(func(d *AppData) { *d = AppData{} })
+	})
+}
+
+func TestCheckGuardCoalesces(t *testing.T) {
+	var (
+		requests  atomic.Int64 // check requests made
+		active    atomic.Int64 // jobs running right now
+		overlaps  atomic.Int64
+		lastStart atomic.Int64 // requests made when the latest check started
+		wg        sync.WaitGroup
+	)
+	job := func() {
+		if active.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		defer active.Add(-1)
+		lastStart.Store(requests.Load())
+		time.Sleep(time.Millisecond)
+	}
+	for w := 0; w < stressWorkers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < stressRequests/stressWorkers; i++ {
+				if w == 0 && i%5 == 0 {
+					checks.run("backfill", job)
+					continue
+				}
+				requests.Add(1)
+				checks.run("check", job)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if n := overlaps.Load(); n > 0 {
+		t.Errorf("jobs overlapped %d times", n)
+	}
+	if lastStart.Load() != requests.Load() {
+		t.Errorf("no check ran after the last request (%d of %d requests seen)", lastStart.Load(), requests.Load())
+	}
+}
+
+func TestCheckGuardSurvivesPanic(t *testing.T) {
+	var g checkGuard
+	backfilled := false
+	if !g.run("check", func() {
+		g.run("backfill", func() { backfilled = true })
+		panic("scraper bug")
+	}) {
+		t.Fatal("first job didn't run")
+	}
+	if !backfilled {
+		t.Error("job queued behind the one that panicked didn't run")
+	}
+	checked := false
+	if !g.run("check", func() { checked = true }) || !checked {
+		t.Error("guard still held after a job panicked")
+	}
+}
+
+// A check trimming a full history mustn't touch the copy the list shows
+func TestHistoryTrimDoesNotRaceWithView(t *testing.T) {
+	useScratchStore(t)
+	now := time.Now()
+	a := newAsset(Asset{Title: "Asset", URL: "https://www.fab.com/listings/a", Category: CategoryFree}, "stress", now)
+	for i := 0; i < maxAssetHistory; i++ {
+		a.addEvent(AssetEvent{Time: now, Type: eventChanged, Field: "price"})
+	}
+	state.update(func(d *AppData) { d.SeenAssets[a.URL] = a })
+	view.refresh()
+	row, ok := view.row(tabFree, 0)
+	if !ok {
+		t.Fatal("asset not in the list")
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			state.update(func(d *AppData) {
+				stored := d.SeenAssets[a.URL]
+				stored.addEvent(AssetEvent{Time: now, Type: eventChanged, Field: "price"})
+				d.SeenAssets[a.URL] = stored
+			})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			for _, e := range row.Asset.History {
+				_ = e.Field
+			}
+		}
+	}()
+	wg.Wait()
+
+	state.read(func(d *AppData) {
+		if n := len(d.SeenAssets[a.URL].History); n != maxAssetHistory {
+			t.Errorf("history has %d events, want %d", n, maxAssetHistory)
+		}
+	})
+}
+
+func TestStateConcurrentAccess(t *testing.T) {
+	useScratchStore(t)
+
+	var runs atomic.Int64
+	fakeCheck := func() {
+		n := runs.Add(1)
+		url := fmt.Sprintf("https://www.fab.com/listings/%08x-0000-4000-8000-000000000000", n)
+		result := &FetchResult{Free: []Asset{{Title: "Asset", URL: url, Category: CategoryFree}}, Complete: true}
+		state.update(func(d *AppData) {
+			now := time.Now()
+			setSourceStatus(d, "stress", SourceStatus{LastRun: now, Found: 1, Broken: recordYield(d, "stress", result)})
+			mergeAssets(d, "stress", result.Free, now)
+			recordListing(d, "stress", result, now)
+			mergeBatches(d, []Batch{{ArticleURL: "https://unrealsource.com/d/stress/", AssetURLs: []string{url}}})
+		})
+		saveData()
+		listsChanged()
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < stressWorkers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < stressRequests/stressWorkers; i++ {
+				checks.run("check", fakeCheck)
+
+				switch i % 4 {
+				case 0:
+					// What the UI does on its own goroutine
+					state.read(func(d *AppData) {
+						brokenSources(d)
+						failingSources(d)
+					})
+					view.counts()
+					if row, ok := view.row(tabFree, 0); ok {
+						_ = row.Asset.History
+					}
+				case 1:
+					// Enrichment: pick assets, "fetch", then apply to the
+					// ones still there
+					var keys []string
+					state.read(func(d *AppData) {
+						keys = unenriched(d, func(a Asset) bool { return a.Category == CategoryFree })
+					})
+					state.update(func(d *AppData) {
+						for _, key := range keys {
+							if a, ok := d.SeenAssets[key]; ok {
+								a.EnrichedAt = time.Now()
+								d.SeenAssets[key] = a
+							}
+						}
+					})
+				case 2:
+					if w == 0 {
+						clearHistory()
+					} else {
+						view.setSearch("asset")
+					}
+				case 3:
+					state.storeError()
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if err := state.save(); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.read(func(d *AppData) {
+		if len(saved.SeenAssets) != len(d.SeenAssets) {
+			t.Errorf("saved %d assets, state has %d", len(saved.SeenAssets), len(d.SeenAssets))
+		}
+		for key, a := range d.SeenAssets {
+			if a.Category == "" || a.URL != key {
+				t.Errorf("incomplete asset %s", key)
+			}
+		}
+	})
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Store names accepted by the "store" setting
+const (
+	storeJSON   = "json"
+	storeSQLite = "sqlite"
+)
+
+// Store persists AppData between runs. The JSON store keeps everything in
+// seen_assets.json and rewrites it on every save; the SQLite store keeps
+// assets, batches, check runs and user state in tables and only writes the
+// rows that changed.
+type Store interface {
+	// Load returns the saved data, or empty data if nothing was saved yet.
+	// On error it still returns what could be read.
+	Load() (AppData, error)
+	Save(data *AppData) error
+	Close() error
+}
+
+var store Store
+
+// openStore opens the store chosen in the config
+func openStore() (Store, error) {
+	switch config.Store {
+	case storeSQLite:
+		return openSQLiteStore(filepath.Join(dataDir, sqliteFileName), dataFile)
+	default:
+		return &jsonStore{path: dataFile, backups: config.Backups}, nil
+	}
+}
+
+// jsonStore is the original single-file store. Saves replace the file
+// atomically and keep rotating backups next to it.
+type jsonStore struct {
+	path    string
+	backups int
+	// Why the existing file couldn't be read. Saving would overwrite
+	// whatever of the user's history is still in it, so it's refused.
+	unreadable error
+}
+
+func (s *jsonStore) Load() (AppData, error) {
+	data, err := readDataFile(s.path)
+	s.unreadable = err
+	return data, err
+}
+
+func (s *jsonStore) Save(data *AppData) error {
+	if s.unreadable != nil {
+		return fmt.Errorf("not overwriting %s, which couldn't be read (%v); run \"unreal-free-assets repair\" or restore a backup (%s)",
+			filepath.Base(s.path), s.unreadable, filepath.Base(backupName(s.path, 1)))
+	}
+	raw, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := rotateBackups(s.path, s.backups); err != nil {
+		log.Printf("Backing up %s: %v", s.path, err)
+	}
+	return writeFileAtomic(s.path, raw, 0644)
+}
+
+func (s *jsonStore) Close() error { return nil }
+
+// readDataFile reads and migrates a seen_assets.json. A missing file is
+// empty data, not an error.
+func readDataFile(path string) (AppData, error) {
+	data := AppData{SeenAssets: make(map[string]Asset)}
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return data, nil
+	}
+	if err != nil {
+		return data, err
+	}
+
+	migrated, migrateErr := migrateData(raw)
+	if migrateErr == nil {
+		raw = migrated
+	}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return data, fmt.Errorf("%s: %w", path, err)
+	}
+	if data.SeenAssets == nil {
+		data.SeenAssets = make(map[string]Asset)
+	}
+	if migrateErr != nil {
+		return data, fmt.Errorf("%s: %w", path, migrateErr)
+	}
+	return data, nil
+}
+
+// mergeAppData adds what src has and dst doesn't: assets, batches, source
+// statuses and check history. Entries dst already has are kept as they are.
+// It returns the number of assets added.
+func mergeAppData(dst *AppData, src AppData) int {
+	if dst.SeenAssets == nil {
+		dst.SeenAssets = make(map[string]Asset)
+	}
+	added := 0
+	for key, a := range src.SeenAssets {
+		if _, ok := dst.SeenAssets[key]; !ok {
+			dst.SeenAssets[key] = a
+			added++
+		}
+	}
+	for key, b := range src.Batches {
+		if dst.Batches == nil {
+			dst.Batches = make(map[string]Batch)
+		}
+		if _, ok := dst.Batches[key]; !ok {
+			dst.Batches[key] = b
+		}
+	}
+	for name, st := range src.Sources {
+		if dst.Sources == nil {
+			dst.Sources = make(map[string]SourceStatus)
+		}
+		if _, ok := dst.Sources[name]; !ok {
+			dst.Sources[name] = st
+		}
+	}
+	for name, history := range src.YieldHistory {
+		if dst.YieldHistory == nil {
+			dst.YieldHistory = make(map[string][]YieldSample)
+		}
+		if len(dst.YieldHistory[name]) == 0 {
+			dst.YieldHistory[name] = history
+		}
+	}
+	if src.LastCheck.After(dst.LastCheck) {
+		dst.LastCheck = src.LastCheck
+	}
+	return added
+}
+
+// importJSONCommand copies a seen_assets.json into the SQLite database
+func importJSONCommand(args []string) error {
+	path := dataFile
+	if len(args) > 0 {
+		path = args[0]
+	}
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	db, err := openSQLiteStore(filepath.Join(dataDir, sqliteFileName), "")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	added, err := db.importJSON(path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d new assets from %s into %s\n", added, path, db.path)
+	if config.Store != storeSQLite {
+		fmt.Println(`Set "store": "sqlite" in config.json to use the database`)
+	}
+	return nil
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteFileName = "assets.db"
+
+// sqliteMigrations upgrade the database schema one version at a time; the
+// schema version is kept in PRAGMA user_version. Add new migrations to the
+// end, never change one that has shipped.
+var sqliteMigrations = []string{
+	// 1: assets, batches, check runs and user state. Assets, batches and
+	// source statuses are stored as JSON with the columns needed for
+	// lookups and ordering next to it.
+	`CREATE TABLE assets (
+		url        TEXT PRIMARY KEY,
+		category   TEXT NOT NULL,
+		first_seen TEXT NOT NULL,
+		data       TEXT NOT NULL
+	);
+	CREATE INDEX assets_category ON assets (category, first_seen);
+
+	CREATE TABLE batches (
+		article_url TEXT PRIMARY KEY,
+		announced   TEXT NOT NULL,
+		data        TEXT NOT NULL
+	);
+
+	CREATE TABLE check_runs (
+		id           INTEGER PRIMARY KEY,
+		source       TEXT NOT NULL,
+		time         TEXT NOT NULL,
+		found        INTEGER NOT NULL,
+		partial      INTEGER NOT NULL,
+		rule_matches TEXT
+	);
+	CREATE INDEX check_runs_source ON check_runs (source, time);
+
+	CREATE TABLE source_status (
+		name TEXT PRIMARY KEY,
+		data TEXT NOT NULL
+	);
+
+	CREATE TABLE user_state (
+		key   TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);`,
+}
+
+// sqliteStore keeps AppData in a SQLite database. It remembers what it last
+// loaded or saved, so a save only writes rows that changed and check runs
+// that are new.
+type sqliteStore struct {
+	path string
+	db   *sql.DB
+
+	mu       sync.Mutex
+	assets   map[string][]byte
+	batches  map[string][]byte
+	sources  map[string][]byte
+	lastRuns map[string]time.Time
+}
+
+// openSQLiteStore opens or creates the database at path and brings its
+// schema up to date. A new database is filled from jsonPath, if that file
+// exists.
+func openSQLiteStore(path, jsonPath string) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// One connection: writes are serialized anyway, and it keeps
+	// transactions from waiting on each other
+	db.SetMaxOpenConns(1)
+
+	s := &sqliteStore{path: path, db: db, lastRuns: make(map[string]time.Time)}
+	created, err := s.migrate()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if created && jsonPath != "" {
+		if _, err := os.Stat(jsonPath); err == nil {
+			added, err := s.importJSON(jsonPath)
+			if err != nil {
+				log.Printf("Importing %s: %v", jsonPath, err)
+			} else {
+				log.Printf("Imported %d assets from %s", added, jsonPath)
+			}
+		}
+	}
+	return s, nil
+}
+
+// migrate applies the migrations the database doesn't have yet and reports
+// whether it was just created. A database emptied by "Clear All" is not new:
+// importing into it would bring the cleared history back.
+func (s *sqliteStore) migrate() (bool, error) {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return false, err
+	}
+	created := version == 0
+	if version > len(sqliteMigrations) {
+		return false, fmt.Errorf("database schema version %d is newer than this app (%d)", version, len(sqliteMigrations))
+	}
+
+	for ; version < len(sqliteMigrations); version++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return false, err
+		}
+		if _, err := tx.Exec(sqliteMigrations[version]); err != nil {
+			tx.Rollback()
+			return false, fmt.Errorf("migrating schema to version %d: %w", version+1, err)
+		}
+		// PRAGMA doesn't take parameters
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+		if err := tx.Commit(); err != nil {
+			return false, err
+		}
+	}
+	return created, nil
+}
+
+// importJSON merges a seen_assets.json into the database, keeping assets
+// the database already has
+func (s *sqliteStore) importJSON(path string) (int, error) {
+	src, err := readDataFile(path)
+	if err != nil {
+		return 0, err
+	}
+	data, err := s.Load()
+	if err != nil {
+		return 0, err
+	}
+	added := mergeAppData(&data, src)
+	return added, s.Save(&data)
+}
+
+func (s *sqliteStore) Load() (AppData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data := AppData{
+		Version:      dataVersion,
+		SeenAssets:   make(map[string]Asset),
+		Sources:      make(map[string]SourceStatus),
+		Batches:      make(map[string]Batch),
+		YieldHistory: make(map[string][]YieldSample),
+	}
+	s.assets = make(map[string][]byte)
+	s.batches = make(map[string][]byte)
+	s.sources = make(map[string][]byte)
+	s.lastRuns = make(map[string]time.Time)
+
+	// A row that doesn't decode is skipped and reported, so one bad
+	// entry doesn't lose the rest
+	var errs []error
+	err := s.loadRows("SELECT url, data FROM assets", func(key string, raw []byte) error {
+		var a Asset
+		if err := json.Unmarshal(raw, &a); err != nil {
+			return err
+		}
+		data.SeenAssets[key] = a
+		s.assets[key] = raw
+		return nil
+	}, &errs)
+	if err != nil {
+		return data, err
+	}
+	err = s.loadRows("SELECT article_url, data FROM batches", func(key string, raw []byte) error {
+		var b Batch
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return err
+		}
+		data.Batches[key] = b
+		s.batches[key] = raw
+		return nil
+	}, &errs)
+	if err != nil {
+		return data, err
+	}
+	err = s.loadRows("SELECT name, data FROM source_status", func(key string, raw []byte) error {
+		var st SourceStatus
+		if err := json.Unmarshal(raw, &st); err != nil {
+			return err
+		}
+		data.Sources[key] = st
+		s.sources[key] = raw
+		return nil
+	}, &errs)
+	if err != nil {
+		return data, err
+	}
+
+	if err := s.loadCheckRuns(&data); err != nil {
+		return data, err
+	}
+
+	var lastCheck string
+	err = s.db.QueryRow("SELECT value FROM user_state WHERE key = 'last_check'").Scan(&lastCheck)
+	if err != nil && err != sql.ErrNoRows {
+		return data, err
+	}
+	if lastCheck != "" {
+		data.LastCheck, _ = time.Parse(time.RFC3339Nano, lastCheck)
+	}
+	return data, errors.Join(errs...)
+}
+
+// loadRows calls fn for each (key, JSON) row of query, collecting row errors in errs
+func (s *sqliteStore) loadRows(query string, fn func(key string, raw []byte) error, errs *[]error) error {
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		var raw []byte
+		if err := rows.Scan(&key, &raw); err != nil {
+			return err
+		}
+		if err := fn(key, raw); err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	return rows.Err()
+}
+
+// loadCheckRuns reads the latest yieldHistorySize runs of each source. Older
+// runs stay in the table.
+func (s *sqliteStore) loadCheckRuns(data *AppData) error {
+	rows, err := s.db.Query(`SELECT source, time, found, partial, rule_matches FROM (
+		SELECT *, ROW_NUMBER() OVER (PARTITION BY source ORDER BY time DESC) AS n FROM check_runs
+	) WHERE n <= ? ORDER BY source, time`, yieldHistorySize)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var source, at string
+		var sample YieldSample
+		var ruleMatches sql.NullString
+		if err := rows.Scan(&source, &at, &sample.Found, &sample.Partial, &ruleMatches); err != nil {
+			return err
+		}
+		sample.Time, _ = time.Parse(time.RFC3339Nano, at)
+		if ruleMatches.Valid {
+			json.Unmarshal([]byte(ruleMatches.String), &sample.RuleMatches)
+		}
+		data.YieldHistory[source] = append(data.YieldHistory[source], sample)
+		s.lastRuns[source] = sample.Time
+	}
+	return rows.Err()
+}
+
+func (s *sqliteStore) Save(data *AppData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	assets := make(map[string][]byte, len(data.SeenAssets))
+	for key, a := range data.SeenAssets {
+		raw, err := json.Marshal(a)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		assets[key] = raw
+		if bytes.Equal(s.assets[key], raw) {
+			continue
+		}
+		_, err = tx.Exec(`INSERT INTO assets (url, category, first_seen, data) VALUES (?, ?, ?, ?)
+			ON CONFLICT (url) DO UPDATE SET category = excluded.category, first_seen = excluded.first_seen, data = excluded.data`,
+			key, a.Category, formatDBTime(a.FirstSeen), raw)
+		if err != nil {
+			return err
+		}
+	}
+	if err := deleteMissing(tx, "DELETE FROM assets WHERE url = ?", s.assets, assets); err != nil {
+		return err
+	}
+
+	batches := make(map[string][]byte, len(data.Batches))
+	for key, b := range data.Batches {
+		raw, err := json.Marshal(b)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		batches[key] = raw
+		if bytes.Equal(s.batches[key], raw) {
+			continue
+		}
+		_, err = tx.Exec(`INSERT INTO batches (article_url, announced, data) VALUES (?, ?, ?)
+			ON CONFLICT (article_url) DO UPDATE SET announced = excluded.announced, data = excluded.data`,
+			key, formatDBTime(b.Announced), raw)
+		if err != nil {
+			return err
+		}
+	}
+	if err := deleteMissing(tx, "DELETE FROM batches WHERE article_url = ?", s.batches, batches); err != nil {
+		return err
+	}
+
+	sources := make(map[string][]byte, len(data.Sources))
+	for name, st := range data.Sources {
+		raw, err := json.Marshal(st)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		sources[name] = raw
+		if bytes.Equal(s.sources[name], raw) {
+			continue
+		}
+		_, err = tx.Exec(`INSERT INTO source_status (name, data) VALUES (?, ?)
+			ON CONFLICT (name) DO UPDATE SET data = excluded.data`, name, raw)
+		if err != nil {
+			return err
+		}
+	}
+	if err := deleteMissing(tx, "DELETE FROM source_status WHERE name = ?", s.sources, sources); err != nil {
+		return err
+	}
+
+	// Check runs are only ever added; YieldHistory holds the latest few
+	lastRuns := make(map[string]time.Time, len(data.YieldHistory))
+	for source, history := range data.YieldHistory {
+		last := s.lastRuns[source]
+		for _, sample := range history {
+			if !sample.Time.After(s.lastRuns[source]) {
+				continue
+			}
+			var ruleMatches interface{}
+			if sample.RuleMatches != nil {
+				raw, _ := json.Marshal(sample.RuleMatches)
+				ruleMatches = string(raw)
+			}
+			_, err := tx.Exec("INSERT INTO check_runs (source, time, found, partial, rule_matches) VALUES (?, ?, ?, ?, ?)",
+				source, formatDBTime(sample.Time), sample.Found, sample.Partial, ruleMatches)
+			if err != nil {
+				return err
+			}
+			last = sample.Time
+		}
+		lastRuns[source] = last
+	}
+
+	_, err = tx.Exec(`INSERT INTO user_state (key, value) VALUES ('last_check', ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value`, formatDBTime(data.LastCheck))
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.assets, s.batches, s.sources = assets, batches, sources
+	for source, last := range lastRuns {
+		s.lastRuns[source] = last
+	}
+	return nil
+}
+
+// deleteMissing deletes the rows that were saved before but are gone now
+func deleteMissing(tx *sql.Tx, query string, before, now map[string][]byte) error {
+	for key := range before {
+		if _, ok := now[key]; ok {
+			continue
+		}
+		if _, err := tx.Exec(query, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// formatDBTime formats times so they sort as text
+func formatDBTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000000Z07:00")
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteImportsJSONOnlyWhenCreated(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, dataFileName)
+	url := "https://www.fab.com/listings/00000000-0000-4000-8000-000000000001"
+	raw, err := json.Marshal(AppData{Version: dataVersion, SeenAssets: map[string]Asset{
+		url: {Title: "Asset", URL: url, Category: CategoryFree},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(jsonPath, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+	dbPath := filepath.Join(dir, sqliteFileName)
+
+	s, err := openSQLiteStore(dbPath, jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.SeenAssets) != 1 {
+		t.Fatalf("new database has %d assets, want the 1 from %s", len(d.SeenAssets), dataFileName)
+	}
+
+	// Clear All, then start again
+	d.SeenAssets = make(map[string]Asset)
+	if err := s.Save(&d); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	s, err = openSQLiteStore(dbPath, jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if d, err = s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.SeenAssets) != 0 {
+		t.Errorf("cleared database has %d assets after reopening, want 0", len(d.SeenAssets))
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Integrity check and repair of seen_assets.json. The app won't overwrite a
+// data file it can't read; "verify" tells what's wrong with one, and
+// "repair" salvages every record it can into a fresh file.
+
+var validCategories = map[string]bool{
+	CategoryFree: true, CategoryLatest: true, CategoryGames: true, CategoryPermanent: true,
+}
+
+// dataProblem is one thing wrong with a data file and what repair does about it
+type dataProblem struct {
+	where   string
+	problem string
+	fix     string
+}
+
+// dataCheck is the result of checking a data file
+type dataCheck struct {
+	now      time.Time
+	version  int
+	entries  int // assets in the file, readable or not
+	problems []dataProblem
+	// The readable parts, in the file's own version
+	salvage map[string]interface{}
+	// Why the file can't be repaired at all
+	fatal error
+}
+
+func (c *dataCheck) add(where, problem, fix string) {
+	c.problems = append(c.problems, dataProblem{where, problem, fix})
+}
+
+// checkDataFile validates a data file against the AppData schema. It reads
+// as far as it can: a file cut off mid-write still yields the assets before
+// the cut. Assets missing a first_seen time get now. Older files are checked
+// as they are, so their duplicates show; repaired migrates them.
+func checkDataFile(data []byte, now time.Time) *dataCheck {
+	c := &dataCheck{now: now, version: 1, salvage: make(map[string]interface{})}
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	err := readObject(dec, func(key string) error {
+		if key == "seen_assets" {
+			assets := make(map[string]interface{})
+			c.salvage["seen_assets"] = assets
+			return readObject(dec, func(key string) error {
+				var raw json.RawMessage
+				if err := dec.Decode(&raw); err != nil {
+					return err
+				}
+				c.entries++
+				if asset := c.checkAsset(key, raw); asset != nil {
+					assets[key] = asset
+				}
+				return nil
+			})
+		}
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		c.checkSection(key, raw)
+		return nil
+	})
+	if err != nil {
+		c.add("file", fmt.Sprintf("unreadable from byte %d on: %v", dec.InputOffset(), err), "everything before it is kept")
+	}
+
+	if c.version > dataVersion {
+		c.fatal = fmt.Errorf("data file version %d is newer than this app (%d)", c.version, dataVersion)
+	}
+	c.checkDuplicates()
+	return c
+}
+
+// readObject reads a JSON object from dec, calling member for each key with
+// the decoder positioned at its value. It stops at the first error.
+func readObject(dec *json.Decoder, member func(key string) error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return fmt.Errorf("expected an object, found %v", tok)
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		if err := member(key); err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
+
+// checkSection validates a top-level field other than seen_assets
+func (c *dataCheck) checkSection(key string, raw json.RawMessage) {
+	switch key {
+	case "version":
+		if err := json.Unmarshal(raw, &c.version); err != nil {
+			c.add(key, err.Error(), fmt.Sprintf("assumed to be the current version (%d)", dataVersion))
+			c.version = dataVersion
+		}
+		c.salvage[key] = c.version
+	case "last_check":
+		var t time.Time
+		if err := json.Unmarshal(raw, &t); err != nil {
+			c.add(key, err.Error(), "dropped")
+			return
+		}
+		c.salvage[key] = raw
+	case "sources":
+		c.salvage[key] = checkEntries(c, key, raw, func() interface{} { return &SourceStatus{} })
+	case "batches":
+		c.salvage[key] = checkEntries(c, key, raw, func() interface{} { return &Batch{} })
+	case "yield_history":
+		c.salvage[key] = checkEntries(c, key, raw, func() interface{} { return &[]YieldSample{} })
+	default:
+		c.add(key, "unknown field", "dropped")
+	}
+}
+
+// checkEntries keeps the entries of a map-valued field that decode into the
+// type newValue returns
+func checkEntries(c *dataCheck, section string, raw json.RawMessage, newValue func() interface{}) map[string]interface{} {
+	kept := make(map[string]interface{})
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		c.add(section, err.Error(), "dropped")
+		return kept
+	}
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		entry := entries[key]
+		if err := json.Unmarshal(entry, newValue()); err != nil {
+			c.add(section+" "+key, err.Error(), "dropped")
+			continue
+		}
+		var v interface{}
+		json.Unmarshal(entry, &v)
+		kept[key] = v
+	}
+	return kept
+}
+
+// checkAsset validates one seen_assets entry and returns what can be kept
+// of it, or nil if nothing can
+func (c *dataCheck) checkAsset(key string, raw json.RawMessage) map[string]interface{} {
+	where := "asset " + key
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+		c.add(where, "not an object", "dropped")
+		return nil
+	}
+
+	// Drop fields of the wrong type one by one, so a bad date doesn't
+	// lose the whole asset
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if c.legacyField(name, fields[name]) {
+			continue
+		}
+		one, _ := json.Marshal(map[string]json.RawMessage{name: fields[name]})
+		if err := json.Unmarshal(one, &Asset{}); err != nil {
+			c.add(where, fmt.Sprintf("%s: %v", name, err), "field dropped")
+			delete(fields, name)
+		}
+	}
+	var a Asset
+	cleaned, _ := json.Marshal(fields)
+	json.Unmarshal(cleaned, &a)
+
+	var asset map[string]interface{}
+	json.Unmarshal(cleaned, &asset)
+
+	if strings.TrimSpace(a.URL) == "" {
+		if strings.TrimSpace(key) == "" {
+			c.add(where, "missing url", "dropped")
+			return nil
+		}
+		c.add(where, "missing url", "taken from its key")
+		a.URL = key
+		asset["url"] = key
+	}
+	if a.Category == "" {
+		c.add(where, "missing category", "dropped")
+		return nil
+	}
+	if !validCategories[a.Category] {
+		c.add(where, fmt.Sprintf("invalid category %q", a.Category), "dropped")
+		return nil
+	}
+	if strings.TrimSpace(a.Title) == "" {
+		if title := titleFromSlug(a.URL); title != "" {
+			c.add(where, "missing title", "made up from the URL")
+			asset["title"] = title
+			asset["title_guessed"] = true
+		} else {
+			c.add(where, "missing title", "kept without one")
+		}
+	}
+	if a.FirstSeen.IsZero() {
+		c.add(where, "missing first_seen", "set to the time of the repair")
+		asset["first_seen"] = c.now.Format(time.RFC3339)
+	}
+	if key != a.URL {
+		c.add(where, "key differs from url "+a.URL, "stored under its canonical URL")
+	} else if canonical := canonicalURL(key); key != canonical {
+		c.add(where, "not stored under its canonical URL "+canonical, "moved there")
+	}
+	return asset
+}
+
+// legacyField reports whether an asset field is in the layout of the file's
+// own version rather than the current one; migration converts it
+func (c *dataCheck) legacyField(name string, raw json.RawMessage) bool {
+	switch name {
+	case "expires_at":
+		// Free text until version 2
+		var text string
+		return c.version < 2 && json.Unmarshal(raw, &text) == nil
+	}
+	return false
+}
+
+// checkDuplicates reports assets that share a canonical URL
+func (c *dataCheck) checkDuplicates() {
+	assets, _ := c.salvage["seen_assets"].(map[string]interface{})
+	byURL := make(map[string][]string)
+	for key, v := range assets {
+		url, _ := v.(map[string]interface{})["url"].(string)
+		byURL[canonicalURL(url)] = append(byURL[canonicalURL(url)], key)
+	}
+	var dups []string
+	for url, keys := range byURL {
+		if len(keys) > 1 {
+			dups = append(dups, url)
+		}
+	}
+	sort.Strings(dups)
+	for _, url := range dups {
+		keys := byURL[url]
+		sort.Strings(keys)
+		c.add("asset "+url, fmt.Sprintf("%d entries share this canonical URL: %s", len(keys), strings.Join(keys, ", ")), "merged into one")
+	}
+}
+
+// repaired migrates the salvaged data to the current version, merging
+// duplicates, and returns it ready to save
+func (c *dataCheck) repaired() (AppData, error) {
+	var data AppData
+	if c.fatal != nil {
+		return data, c.fatal
+	}
+	raw, err := json.Marshal(c.salvage)
+	if err != nil {
+		return data, err
+	}
+	if raw, err = migrateData(raw); err != nil {
+		return data, err
+	}
+	// Files already at the current version skip the migration that
+	// merges duplicates, so run it again; it changes nothing otherwise
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return data, err
+	}
+	migrateCanonicalURLs(fields)
+	if raw, err = json.Marshal(fields); err != nil {
+		return data, err
+	}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return data, err
+	}
+	if data.SeenAssets == nil {
+		data.SeenAssets = make(map[string]Asset)
+	}
+	data.Version = dataVersion
+	return data, nil
+}
+
+// printDataCheck prints a check's findings and returns the repaired data
+func printDataCheck(path string, c *dataCheck, showFixes bool) (AppData, error) {
+	fmt.Printf("%s: version %d, %d assets\n", path, c.version, c.entries)
+	for _, p := range c.problems {
+		if showFixes {
+			fmt.Printf("  %s: %s (%s)\n", p.where, p.problem, p.fix)
+		} else {
+			fmt.Printf("  %s: %s\n", p.where, p.problem)
+		}
+	}
+	data, err := c.repaired()
+	if err != nil {
+		return data, fmt.Errorf("can't be repaired: %w", err)
+	}
+	if len(c.problems) == 0 {
+		fmt.Println("No problems found")
+	} else {
+		fmt.Printf("%d problems; %d assets can be salvaged\n", len(c.problems), len(data.SeenAssets))
+	}
+	return data, nil
+}
+
+func verifyCommand(args []string) error {
+	path := dataFile
+	if len(args) > 0 {
+		path = args[0]
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	c := checkDataFile(raw, time.Now())
+	if _, err := printDataCheck(path, c, false); err != nil {
+		return err
+	}
+	if len(c.problems) > 0 {
+		return fmt.Errorf("%d problems found (run repair -dry-run to see what repair would do)", len(c.problems))
+	}
+	return nil
+}
+
+func repairCommand(args []string) error {
+	fs := flag.NewFlagSet("repair", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only report what would be repaired")
+	out := fs.String("o", "", "write the repaired data here instead of replacing the file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	path := dataFile
+	if fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	c := checkDataFile(raw, time.Now())
+	data, err := printDataCheck(path, c, true)
+	if err != nil || *dryRun {
+		return err
+	}
+	if len(c.problems) == 0 && *out == "" {
+		return nil
+	}
+
+	target := *out
+	if target == "" {
+		// Keep the original next to the repaired file
+		target = path
+		saved := path + ".broken-" + time.Now().Format("20060102-150405")
+		if err := copyFileAtomic(path, saved); err != nil {
+			return err
+		}
+		fmt.Printf("Original kept as %s\n", saved)
+	}
+	repaired, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(target, repaired, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d assets to %s\n", len(data.SeenAssets), target)
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tabs of the main window, in order
+const (
+	tabFree = iota
+	tabGames
+	tabPermanent
+	tabLatest
+	tabCount
+)
+
+// listView is what the asset lists show: the assets copied out of the app
+// state, filtered by the search term and grouped into rows. Checks and
+// backfills refresh it from their goroutines while the list widgets read it
+// from the UI's, so every access goes through mu. State transactions are
+// taken inside mu, never the other way round.
+type listView struct {
+	mu      sync.Mutex
+	search  string
+	assets  [tabCount][]Asset // everything, sorted for display
+	batches []Batch
+	shown   [tabCount]int // assets matching the search
+	rows    [tabCount][]listRow
+}
+
+var view listView
+
+// refresh copies the assets out of the app state and reapplies the search
+func (v *listView) refresh() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	state.read(func(d *AppData) {
+		v.assets[tabFree], v.assets[tabGames], v.assets[tabPermanent], v.assets[tabLatest] = getSortedAssets(d)
+		v.batches = sortedBatches(d)
+	})
+	v.filterLocked()
+}
+
+// setSearch filters the lists by a search term
+func (v *listView) setSearch(term string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.search = strings.ToLower(strings.TrimSpace(term))
+	v.filterLocked()
+}
+
+func (v *listView) filterLocked() {
+	var shown [tabCount][]Asset
+	for tab, assets := range v.assets {
+		for _, a := range assets {
+			if matchesSearch(tab, a, v.search) {
+				shown[tab] = append(shown[tab], a)
+			}
+		}
+		v.shown[tab] = len(shown[tab])
+	}
+	now := time.Now()
+	v.rows[tabFree] = groupByBatch(shown[tabFree], v.batches, now)
+	v.rows[tabGames] = groupGames(shown[tabGames], now)
+	v.rows[tabPermanent] = assetRows(shown[tabPermanent])
+	v.rows[tabLatest] = assetRows(shown[tabLatest])
+}
+
+// matchesSearch reports whether an asset on a tab matches a lowercase search term
+func matchesSearch(tab int, a Asset, term string) bool {
+	if term == "" {
+		return true
+	}
+	fields := []string{a.Title}
+	switch tab {
+	case tabFree:
+		fields = append(fields, a.URL, a.Seller)
+	case tabGames:
+		fields = append(fields, a.Seller)
+	case tabPermanent:
+		fields = append(fields, a.Seller, a.ListingCategory)
+	case tabLatest:
+		fields = append(fields, a.URL)
+	}
+	for _, f := range fields {
+		if strings.Contains(strings.ToLower(f), term) {
+			return true
+		}
+	}
+	return false
+}
+
+// length is the number of rows on a tab
+func (v *listView) length(tab int) int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return len(v.rows[tab])
+}
+
+// row returns row i of a tab, if there is one
+func (v *listView) row(tab, i int) (listRow, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if i < 0 || i >= len(v.rows[tab]) {
+		return listRow{}, false
+	}
+	return v.rows[tab][i], true
+}
+
+// counts returns the number of assets per tab, in all and matching the search
+func (v *listView) counts() (total, shown [tabCount]int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for tab, assets := range v.assets {
+		total[tab] = len(assets)
+	}
+	return total, v.shown
+}
+
+func getSortedAssets(d *AppData) (free, games, permanent, latest []Asset) {
+	for _, asset := range d.SeenAssets {
+		switch asset.Category {
+		case CategoryFree:
+			free = append(free, asset)
+		case CategoryGames:
+			games = append(games, asset)
+		case CategoryPermanent:
+			permanent = append(permanent, asset)
+		default:
+			latest = append(latest, asset)
+		}
+	}
+	sortByUrgency(free, time.Now())
+	sortByUrgency(games, time.Now())
+	sort.Slice(permanent, func(i, j int) bool {
+		return newsDate(permanent[i]).After(newsDate(permanent[j]))
+	})
+	sort.Slice(latest, func(i, j int) bool {
+		return newsDate(latest[i]).After(newsDate(latest[j]))
+	})
+	return free, games, permanent, latest
+}
+
+// sortByUrgency orders free assets that are still claimable by soonest
+// deadline, then those without a known deadline, then expired ones, newest first
+func sortByUrgency(free []Asset, now time.Time) {
+	rank := func(a Asset) int {
+		switch {
+		case isExpired(a, now):
+			return 2
+		case a.ExpiresAt.IsZero():
+			return 1
+		}
+		return 0
+	}
+	sort.Slice(free, func(i, j int) bool {
+		ri, rj := rank(free[i]), rank(free[j])
+		if ri != rj {
+			return ri < rj
+		}
+		if ri == 0 && !free[i].ExpiresAt.Equal(free[j].ExpiresAt) {
+			return free[i].ExpiresAt.Before(free[j].ExpiresAt)
+		}
+		return freeAssetDate(free[i]).After(freeAssetDate(free[j]))
+	})
+}
+
+// freeAssetDate is when a free asset was announced, falling back to when we first saw it
+func freeAssetDate(a Asset) time.Time {
+	if !a.Announced.IsZero() {
+		return a.Announced
+	}
+	return a.FirstSeen
+}
+
+// newsDate is when a news item was published, falling back to when we first saw it
+func newsDate(a Asset) time.Time {
+	if !a.Published.IsZero() {
+		return a.Published
+	}
+	return a.FirstSeen
+}